@@ -14,20 +14,40 @@ type App struct {
 func New(cmd *cmds.Command, args []string) (a *App, err error) {
 	// Add the default configuration items for datadir/configfile
 	cmds.GetConfigBase(cmd.Configs, cmd.Name, false)
-	// Add the help function
+	// Add the help function, configuration management and the hidden man
+	// page generator
 	cmd.AddCommand(cmds.Help())
+	cmd.AddCommand(cmds.Config())
+	cmd.AddCommand(cmds.GenMan())
 	a = &App{Command: cmd}
 	// We first parse the CLI args, in case config file location has been
 	// specified
 	if a.launch, _, err = a.Command.ParseCLIArgs(args); log.E.Chk(err) {
 		return
 	}
+	// The dotenv files are read before the config so the profiles and the
+	// config passphrase can be given in them
+	var dot *cmds.DotEnv
+	if dot, err = cmd.LoadDotEnv(); log.E.Chk(err) {
+		return
+	}
+	cmd.SetEnvLookup(dot.Lookup(cmd.EnvOverride()))
 	if err = cmd.LoadConfig(); log.E.Chk(err) {
 		return
 	}
 	a.Command, err = cmds.Init(cmd, nil)
 	a.Envs = cmd.GetEnvs()
-	if err = a.Envs.LoadFromEnvironment(); log.E.Chk(err) {
+	if err = a.Envs.Collisions(); log.E.Chk(err) {
+		return
+	}
+	// and again after, as the config can name more of them
+	if dot, err = cmd.LoadDotEnv(); log.E.Chk(err) {
+		return
+	}
+	if err = cmd.CheckEnv(a.Envs, dot); log.E.Chk(err) {
+		return
+	}
+	if err = a.Envs.Load(dot.Lookup(cmd.EnvOverride())); log.E.Chk(err) {
 		return
 	}
 	// This is done again, to ensure the effect of CLI args take precedence
@@ -26,3 +26,26 @@ func TestNew(t *testing.T) {
 	}
 
 }
+
+func TestNew_DotEnv(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/.env", []byte("POD123_PROFILE=testnet\n"),
+		0600); err != nil {
+		t.FailNow()
+	}
+	if err := os.WriteFile(dir+"/config.toml", []byte(`[profile.testnet]
+LimitUser = "testnet"
+`), 0600); err != nil {
+		t.FailNow()
+	}
+	args := []string{"/random/path/to/server_binary", "--datadir", dir,
+		"--configfile", dir + "/config.toml"}
+	a, err := New(cmds.GetExampleCommands(), args)
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	// the profile selected in the .env file is applied to the config
+	if lu := a.Command.Configs["LimitUser"].String(); lu != "testnet" {
+		t.Fatal(lu)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/mapopt"
 	"github.com/cybriq/proc/pkg/opts/meta"
 	"github.com/cybriq/proc/pkg/util"
 )
@@ -28,6 +30,10 @@ import (
 //   the root Command, and these options must precede any other command
 //   options.
 //
+// - Commands that name positional Args take every argument from the first
+//   one without a '-' prefix as arguments for the Entrypoint, or all of them
+//   if the Command has no options.
+//
 // - If no command is selected, the root Command.Default is selected. This
 //   can optionally be used for subcommands as well, though it is unlikely
 //   needed, if found, the Default of the tip of the Command branch
@@ -90,6 +96,12 @@ func (c *Command) ParseCLIArgs(a []string) (run *Command, runArgs []string, err
 			if util.Norm(commands[i].Name) == "help" {
 				break
 			}
+			// a final command that takes arguments and has no options of its
+			// own gets all of them, including any that start with '-'
+			if len(cmd.Args) > 0 && len(cmd.Configs) == 0 &&
+				i == len(segments)-1 {
+				break
+			}
 			var cursor int
 			for cursor < len(iArgs) {
 				inc := 1
@@ -118,8 +130,11 @@ func (c *Command) ParseCLIArgs(a []string) (run *Command, runArgs []string, err
 								if util.Norm(name) == util.Norm(split[0]) {
 									log.T.F("assigning value '%s' to %s",
 										split[1], split[0])
-									err = cmd.Configs[cfgName].FromString(split[1])
-									if log.E.Chk(err) {
+									err = setArg(cmd.Configs[cfgName], split[1])
+									if err != nil {
+										err = fmt.Errorf("%s: %w",
+											cmd.Path.Child(cfgName), err)
+										log.E.Chk(err)
 										return
 									}
 								}
@@ -137,15 +152,16 @@ func (c *Command) ParseCLIArgs(a []string) (run *Command, runArgs []string, err
 										// check for booleans, which can only be
 										// followed by true or false
 										if cmd.Configs[cfgName].Type() == meta.Bool {
-											if len(iArgs) <= cursor {
-												log.I.Ln("bing")
-												err = cmd.Configs[cfgName].
-													FromString(iArgs[cursor+1])
+											if len(iArgs) > cursor+1 &&
+												cmd.Configs[cfgName].FromString(
+													iArgs[cursor+1]) == nil {
+												// the next value was a truth
+												// value so it is consumed
+												inc++
 											} else {
 												err = cmd.Configs[cfgName].
 													FromString("true")
 											}
-											inc++
 											found = true
 											// next value is not a truth value,
 											// simply assign true and increment
@@ -160,9 +176,13 @@ func (c *Command) ParseCLIArgs(a []string) (run *Command, runArgs []string, err
 										} else {
 											log.T.F("assigning value '%s' to %s",
 												iArgs[cursor+1], cfgName)
-											err = cmd.Configs[cfgName].
-												FromString(iArgs[cursor+1])
-											if log.E.Chk(err) {
+											err = setArg(cmd.Configs[cfgName],
+												iArgs[cursor+1])
+											if err != nil {
+												err = fmt.Errorf("%s: %w",
+													cmd.Path.Child(cfgName),
+													err)
+												log.E.Chk(err)
 												return
 											}
 											inc++
@@ -191,6 +211,11 @@ func (c *Command) ParseCLIArgs(a []string) (run *Command, runArgs []string, err
 							return
 						}
 					}
+				} else if len(cmd.Args) > 0 && i == len(segments)-1 {
+					// the remainder are positional arguments for the
+					// Entrypoint of the Command
+					runArgs = iArgs[cursor:]
+					break
 				} else {
 					err = fmt.Errorf("argument %s missing '-', context %s, "+
 						"most likely misspelled subcommand", arg, iArgs)
@@ -224,3 +249,12 @@ func (c *Command) ParseCLIArgs(a []string) (run *Command, runArgs []string, err
 
 	return
 }
+
+// setArg sets an option from a command line argument. Map options add the
+// entries to the map instead, so they can be given more than once.
+func setArg(op config.Option, s string) error {
+	if m, ok := op.(*mapopt.Opt); ok {
+		return m.Set(s)
+	}
+	return op.FromString(s)
+}
@@ -9,7 +9,10 @@ import (
 
 	log2 "github.com/cybriq/proc/pkg/log"
 	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/enum"
+	"github.com/cybriq/proc/pkg/opts/list"
 	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/opts/secret"
 	"github.com/cybriq/proc/pkg/opts/text"
 	"github.com/cybriq/proc/pkg/opts/toggle"
 	"github.com/cybriq/proc/pkg/path"
@@ -34,8 +37,20 @@ type Command struct {
 	Parent        *Command
 	Commands      Commands
 	Configs       config.Opts
-	Default       []string // specifies default subcommand to execute
+	Default       []string   // specifies default subcommand to execute
+	Args          []string   // names of positional arguments to Entrypoint
+	Group         string     // help section among the sibling commands
+	Order         int        // place in the help section, if not 0
+	Groups        []string   // order of help sections, here and below
+	Hidden        bool       // left out of help, completion and man pages
+	Migrations    Migrations // configuration schema migrations, on the root
+	EnvPrefix     string     // environment variable prefix, on the root
 	sync.Mutex
+	profileDoc map[string]interface{}         // profile sections of the config
+	profiled   map[config.Option]profileValue // values replaced by profiles
+	watch      watchers                       // change subscribers, on the root
+	env        EnvLookup                      // see SetEnvLookup, on the root
+	update     sync.Mutex                     // held by Update, on the root
 }
 
 // Commands are a slice of Command entries
@@ -76,6 +91,8 @@ func GetConfigBase(in config.Opts, appName string, abs bool) {
 		"ConfigFile": text.New(meta.Data{
 			Aliases:     []string{"CF"},
 			Label:       "Configuration File",
+			Group:       "Configuration",
+			Order:       1,
 			Description: "location of configuration file",
 			Documentation: strings.TrimSpace(`
 The configuration file path defines the place where the configuration will be
@@ -84,16 +101,89 @@ loaded from at application startup, and where it will be written if changed.
 			Default: defaultConfigFile,
 		}, text.NormalizeFilesystemPath(abs, appName)),
 
+		"ConfigKeyFile": text.New(meta.Data{
+			Aliases:     []string{"CKF"},
+			Label:       "Configuration Key File",
+			Group:       "Configuration",
+			Description: "file containing the passphrase of an encrypted configuration file",
+			Documentation: strings.TrimSpace(`
+If the configuration file is encrypted, the passphrase is read from this file
+when it is not given by ConfigPassphrase or its environment variable. If none
+of these are set, the passphrase is asked for on the terminal.
+`),
+		}, text.NormalizeFilesystemPath(abs, appName)),
+
+		"ConfigPassphrase": secret.New(meta.Data{
+			Label:       "Configuration Passphrase",
+			Group:       "Configuration",
+			Description: "passphrase of an encrypted configuration file",
+			Documentation: strings.TrimSpace(`
+The passphrase used to open and save an encrypted configuration file. This is
+never written to the configuration file, and is best given in the environment
+or as a file: reference so it does not appear in the process list.
+`),
+		}).SaveAs(secret.SaveOmit),
+
 		"DataDir": text.New(meta.Data{
 			Aliases:     []string{"DD"},
 			Label:       "Data Directory",
+			Group:       "Configuration",
+			Order:       2,
 			Description: "root folder where application data is stored",
 			Default:     defaultDataDir,
 		}, text.NormalizeFilesystemPath(abs, appName)),
 
+		"Profile": list.New(meta.Data{
+			Label:       "Profile",
+			Group:       "Configuration",
+			Description: "named configuration profiles to apply, in order",
+			Documentation: strings.TrimSpace(`
+A profile overlays the configuration file with the values of a section such as
+[profile.testnet.node], and of the file profiles/<name>.toml in the data
+directory, which has the same layout as the configuration file. When several
+profiles are given, later ones replace the values of earlier ones. The values
+set by profiles are not written to the configuration file.
+`),
+		}),
+
+		"Strict": toggle.New(meta.Data{
+			Label:       "Strict",
+			Group:       "Configuration",
+			Description: "treat configuration problems as errors",
+			Documentation: strings.TrimSpace(`
+When set, problems found in the configuration file, such as unknown keys, values
+of the wrong type and invalid choices, stop the application with an error
+listing each of them. Otherwise they are printed as warnings and the valid values
+are used. The same applies to environment variables with the prefix of the
+application that do not match any option.
+`),
+			Default: "false",
+		}),
+
+		"EnvFile": list.New(meta.Data{
+			Aliases:     []string{"ENV-FILE"},
+			Label:       "Environment Files",
+			Group:       "Environment",
+			Description: "dotenv files to read environment variables from",
+			Documentation: strings.TrimSpace(`
+Variables are read from a file named .env in the data directory and in the
+working directory if they exist, and then from each of these files, which must
+exist. Values from later files replace those of earlier ones. Variables that are
+set in the process environment are not replaced unless EnvFileOverride is set.
+`),
+		}),
+
+		"EnvFileOverride": toggle.New(meta.Data{
+			Label:       "Environment Files Override",
+			Group:       "Environment",
+			Description: "let dotenv files replace the process environment",
+			Default:     "false",
+		}),
+
 		"LogCodeLocations": toggle.New(meta.Data{
 			Aliases:     []string{"LCL"},
 			Label:       "Log Code Locations",
+			Group:       "Logging",
 			Description: "whether to print code locations in logs",
 			Documentation: strings.TrimSpace(strings.TrimSpace(`
 Toggles on and off the printing of code locations in logs.
@@ -104,9 +194,11 @@ Toggles on and off the printing of code locations in logs.
 			return
 		}),
 
-		"LogLevel": text.New(meta.Data{
+		"LogLevel": enum.New(meta.Data{
 			Aliases: []string{"LL"},
 			Label:   "Log Level",
+			Group:   "Logging",
+			Order:   1,
 			Description: "Level of logging to print: [ " + log2.LvlStr.String() +
 				" ]",
 			Documentation: strings.TrimSpace(`
@@ -119,42 +211,37 @@ of what log level printers will output. Default is 'info' which means debug and
 trace log statements will not print. 
 `),
 			Default: log2.GetLevelName(log2.Info),
-		}, func(o *text.Opt) (err error) {
-			v := strings.TrimSpace(o.Value().Text())
-			found := false
-			lvl := log2.Info
+			Options: logLevels(),
+		}, func(o *enum.Opt) (err error) {
 			for i := range log2.LvlStr {
-				ll := log2.GetLevelName(i)
-				if util.Norm(v) == strings.TrimSpace(ll) {
-					lvl = i
-					found = true
+				if log2.GetLevelName(i) == o.Value().Text() {
+					log2.SetLogLevel(i)
 				}
 			}
-			if !found {
-				err = fmt.Errorf("log level value %s not valid from %v",
-					v, log2.LvlStr)
-				_ = o.FromString(log2.GetLevelName(lvl))
-			}
-			log2.SetLogLevel(lvl)
 			return
-		}),
+		}).Alias("warning", "warn").Alias("information", "info"),
 
 		"LogFilePath": text.New(meta.Data{
 			Aliases:     Tags("LFP"),
 			Label:       "Log To File",
+			Group:       "Logging",
 			Description: "Write logs to the specified file",
 			Documentation: strings.TrimSpace(`
-Sets the path of the file to write logs to.
+Sets the path of the file to write logs to. Like other text and list options, it
+can refer to the values of other options, such as ${DataDir}, to ${env:NAME}
+environment variables and to ${appdata}, the default data directory.
 `),
-			Default: filepath.Join(defaultDataDir, "log.txt"),
-		}, func(o *text.Opt) (err error) {
-			err = log2.SetLogFilePath(o.Expanded())
-			return
-		}, text.NormalizeFilesystemPath(abs, appName)),
+			Default: filepath.Join("${DataDir}", "log.txt"),
+		}, text.NormalizeFilesystemPath(abs, appName),
+			func(o *text.Opt) (err error) {
+				err = log2.SetLogFilePath(o.Expanded())
+				return
+			}),
 
 		"LogToFile": toggle.New(meta.Data{
 			Aliases:     Tags("LTF"),
 			Label:       "Log To File",
+			Group:       "Logging",
 			Description: "Enable writing of logs",
 			Documentation: strings.TrimSpace(`
 Enables the writing of logs to the file path defined in LogFilePath.
@@ -177,10 +264,21 @@ Enables the writing of logs to the file path defined in LogFilePath.
 	}
 }
 
+// logLevels returns the names of the log levels in ascending order.
+func logLevels() (names []string) {
+	for i := log2.Off; int(i) < len(log2.LvlStr); i++ {
+		names = append(names, log2.GetLevelName(i))
+	}
+	return
+}
+
 // Init sets up a Command to be ready to use. Puts the reverse paths into the
 // tree structure, puts sane defaults into command launchers, runs the hooks on
 // all the defined configuration values, and sets the paths on each Command and
-// Option so that they can be directly interrogated for their location.
+// Option so that they can be directly interrogated for their location. On the
+// root, it also connects the options to the tree so ${name} references in
+// their values can be expanded and changes reach the subscribers of Subscribe
+// and Watch.
 func Init(c *Command, p path.Path) (cmd *Command, err error) {
 	if c.Parent != nil {
 		log.T.Ln("backlinking children of", c.Parent.Name)
@@ -190,6 +288,8 @@ func Init(c *Command, p path.Path) (cmd *Command, err error) {
 	}
 	if p == nil {
 		p = path.Path{c.Name}
+		c.setInterpolators()
+		c.setNotifiers()
 	}
 	c.Path = p // .Parent()
 	for i := range c.Configs {
@@ -203,7 +303,9 @@ func Init(c *Command, p path.Path) (cmd *Command, err error) {
 	c.ForEach(func(cmd *Command, _ int) bool {
 		for i := range cmd.Configs {
 			err = cmd.Configs[i].RunHooks()
-			if log.E.Chk(err) {
+			if err != nil {
+				err = fmt.Errorf("%s: %w", cmd.Path.Child(i), err)
+				log.E.Chk(err)
 				return false
 			}
 		}
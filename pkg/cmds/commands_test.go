@@ -1,14 +1,39 @@
 package cmds
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	log2 "github.com/cybriq/proc/pkg/log"
+	"github.com/cybriq/proc/pkg/opts"
+	integer "github.com/cybriq/proc/pkg/opts/Integer"
+	"github.com/cybriq/proc/pkg/opts/cidr"
 	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/duration"
+	"github.com/cybriq/proc/pkg/opts/endpoint"
+	"github.com/cybriq/proc/pkg/opts/enum"
+	"github.com/cybriq/proc/pkg/opts/float"
+	"github.com/cybriq/proc/pkg/opts/ip"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/mapopt"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/opts/secret"
+	"github.com/cybriq/proc/pkg/opts/text"
+	"github.com/cybriq/proc/pkg/opts/urlopt"
 	"github.com/cybriq/proc/pkg/path"
+	"go.uber.org/atomic"
 )
 
 func TestCommand_Foreach(t *testing.T) {
@@ -123,7 +148,6 @@ func TestCommand_Help(t *testing.T) {
 	ex := GetExampleCommands()
 	ex.AddCommand(Help())
 	o, _ := Init(ex, nil)
-	o.Commands = append(o.Commands)
 	args1 := "/random/path/to/server_binary help"
 	fmt.Println(args1)
 	args1s := strings.Split(args1, " ")
@@ -276,3 +300,1838 @@ func TestCommand_LogToFile(t *testing.T) {
 	if err := os.RemoveAll(ex.Configs["DataDir"].Expanded()); log.E.Chk(err) {
 	}
 }
+
+func TestCommand_Secret(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	o, _ := Init(GetExampleCommands(), nil)
+	op := o.GetOpt(path.From("pod123 wallet pass"))
+	sec := op.(*secret.Opt)
+	if op.String() != secret.Redacted || op.Meta().Default() != secret.Redacted {
+		t.FailNow()
+	}
+	if sec.Secret() == secret.Redacted || sec.Secret() == "" {
+		t.FailNow()
+	}
+	conf, err := o.MarshalText()
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	if strings.Contains(string(conf), sec.Secret()) {
+		t.FailNow()
+	}
+	sec.SaveAs(secret.SaveReference)
+	if conf, err = o.MarshalText(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if !strings.Contains(string(conf), "Pass = \"env:POD123_WALLET_PASS\"") {
+		t.FailNow()
+	}
+	// by default a secret is only saved if it is a reference
+	pw := o.GetOpt(path.From("pod123 password")).(*secret.Opt)
+	if pw.FromString(`pa"ss\w0rd`) != nil {
+		t.FailNow()
+	}
+	if conf, err = o.MarshalText(); log.E.Chk(err) ||
+		strings.Contains(string(conf), "w0rd") ||
+		strings.Contains(string(conf), "Password =") {
+		t.Fatal(string(conf))
+	}
+	pw.SaveAs(secret.SaveValue)
+	if conf, err = o.MarshalText(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	again, _ := Init(GetExampleCommands(), nil)
+	if err = again.UnmarshalText(conf); log.E.Chk(err) ||
+		again.GetOpt(path.From("pod123 password")).(*secret.Opt).Secret() !=
+			`pa"ss\w0rd` {
+		t.Fatal(string(conf))
+	}
+	pw.SaveAs(secret.SaveIfReference)
+	if pw.FromString("env:HOME") != nil {
+		t.FailNow()
+	}
+	if conf, err = o.MarshalText(); log.E.Chk(err) ||
+		!strings.Contains(string(conf), "Password = \"env:HOME\"") {
+		t.Fatal(string(conf))
+	}
+}
+
+func TestCommand_References(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	o, _ := Init(GetExampleCommands(), nil)
+	f, err := os.CreateTemp("", "rpcpass")
+	if err != nil {
+		t.FailNow()
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString("hunter2\n"); err != nil {
+		t.FailNow()
+	}
+	f.Close()
+	op := o.GetOpt(path.From("pod123 password"))
+	if err = op.FromString("file:" + f.Name()); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if op.(*secret.Opt).Secret() != "hunter2" ||
+		op.(*secret.Opt).Raw() != "file:"+f.Name() {
+		t.FailNow()
+	}
+	// references in the configuration file are resolved as well
+	if err = op.FromString(""); log.E.Chk(err) {
+		t.FailNow()
+	}
+	cfgFile := filepath.Join(t.TempDir(), "config.toml")
+	if err = os.WriteFile(cfgFile, []byte("[pod123]\nPassword = \"file:"+
+		f.Name()+"\"\n"), 0600); err != nil {
+		t.FailNow()
+	}
+	if o.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	if err = o.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if op.(*secret.Opt).Secret() != "hunter2" ||
+		op.(*secret.Opt).Raw() != "file:"+f.Name() {
+		t.FailNow()
+	}
+	op = o.GetOpt(path.From("pod123 node proxyuser"))
+	if err = os.Setenv("TEST_PROXY_USER", "someone"); err != nil {
+		t.FailNow()
+	}
+	if err = op.FromString("env:TEST_PROXY_USER"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if op.Expanded() != "someone" || op.String() != "env:TEST_PROXY_USER" {
+		t.FailNow()
+	}
+	envs := Envs{{Name: path.From("pod123 node proxyuser"), Opt: op}}
+	if err = os.Setenv("POD123_NODE_PROXYUSER",
+		"env:TEST_UNSET_VARIABLE"); err != nil {
+		t.FailNow()
+	}
+	defer os.Unsetenv("POD123_NODE_PROXYUSER")
+	err = envs.LoadFromEnvironment()
+	if err == nil || !strings.Contains(err.Error(), "pod123 node proxyuser") {
+		t.FailNow()
+	}
+	// commands are only run once the application enables them
+	if err = op.FromString("cmd:echo ran"); log.E.Chk(err) ||
+		op.Expanded() != "cmd:echo ran" {
+		t.Fatal(op.Expanded())
+	}
+	opts.Register(opts.CommandScheme, opts.CommandProvider)
+	if err = op.FromString("cmd:echo ran"); log.E.Chk(err) ||
+		op.Expanded() != "ran" {
+		t.Fatal(op.Expanded())
+	}
+}
+
+func TestCommand_EncryptedConfig(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex.AddCommand(Config())
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	t.Setenv("POD123_CONFIGPASSPHRASE", "correct horse battery staple")
+	err := ex.SaveConfig()
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	if err = os.Chmod(cfgFile, 0644); err != nil {
+		t.FailNow()
+	}
+	encrypt := ex.GetCommand("pod123 config encrypt")
+	if err = encrypt.Entrypoint(ex, nil); log.E.Chk(err) {
+		t.FailNow()
+	}
+	// the file is replaced, only readable by the owner, with no temporary
+	// file left behind
+	fi, err := os.Stat(cfgFile)
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatal(err, fi.Mode())
+	}
+	if leftover, _ := filepath.Glob(dir + "/.config.toml.*"); len(leftover) > 0 {
+		t.Fatal(leftover)
+	}
+	var b []byte
+	if b, err = os.ReadFile(cfgFile); err != nil {
+		t.FailNow()
+	}
+	if strings.Contains(string(b), "LimitUser") {
+		t.FailNow()
+	}
+	ex.GetOpt(path.From("pod123 limituser")).FromString("changed")
+	if err = ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	ex.GetOpt(path.From("pod123 limituser")).FromString("other")
+	if err = ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 limituser")).String() != "changed" {
+		t.FailNow()
+	}
+	ex.GetOpt(path.From("pod123 configpassphrase")).FromString("wrong")
+	if err = ex.LoadConfig(); err == nil {
+		t.FailNow()
+	}
+	// the passphrase can be given in a dotenv file
+	if err = os.Unsetenv("POD123_CONFIGPASSPHRASE"); err != nil {
+		t.FailNow()
+	}
+	ex2 := GetExampleCommands()
+	GetConfigBase(ex2.Configs, ex2.Name, false)
+	ex2, _ = Init(ex2, nil)
+	if ex2.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	dot := NewDotEnv()
+	if err = dot.Parse(".env", []byte(
+		"POD123_CONFIGPASSPHRASE='correct horse battery staple'\n")); err != nil {
+		t.FailNow()
+	}
+	ex2.SetEnvLookup(dot.Lookup(false))
+	if err = ex2.LoadConfig(); log.E.Chk(err) ||
+		ex2.GetOpt(path.From("pod123 limituser")).String() != "changed" {
+		t.Fatal(err)
+	}
+}
+
+func TestCommand_Migrate(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	old := "[pod123]\nLimitUsername = \"olduser\"\n" +
+		"[pod123.node]\nRPCMaxClient = 7\nUserAgentComments = \"a, b\"\n"
+	if err := os.WriteFile(cfgFile, []byte(old), 0600); err != nil {
+		t.FailNow()
+	}
+	ex.AddMigration(Migration{
+		Version:     2,
+		Description: "move max clients to wallet",
+		Migrate: func(d Document) error {
+			return d.Rename(path.From("pod123 node rpcmaxclient"),
+				path.From("pod123 wallet RPCMaxClients"))
+		},
+	})
+	ex.AddMigration(Migration{
+		Version:     1,
+		Description: "rename LimitUsername and split comments",
+		Migrate: func(d Document) (err error) {
+			if err = d.SplitList(path.From("pod123 node useragentcomments"),
+				","); err != nil {
+				return
+			}
+			return d.Rename(path.From("pod123 LimitUsername"),
+				path.From("pod123 LimitUser"))
+		},
+	})
+	if err := ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 limituser")).String() != "olduser" ||
+		ex.GetOpt(path.From("pod123 wallet rpcmaxclients")).String() != "7" ||
+		ex.GetOpt(path.From("pod123 node useragentcomments")).String() != "a,b" {
+		t.FailNow()
+	}
+	if _, err := os.Stat(cfgFile + ".v0.bak"); err != nil {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(cfgFile)
+	if err != nil || !strings.Contains(string(b), "SchemaVersion = 2") {
+		t.FailNow()
+	}
+	// a file from a newer version of the application is refused
+	newer := strings.Replace(string(b), "SchemaVersion = 2",
+		"SchemaVersion = 3", 1)
+	if err = ex.UnmarshalText([]byte(newer)); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestCommand_ValidateConfig(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	conf := `[pod123]
+LimitUser = "someone"
+LimitUsr = "typo"
+Locale = "fr"
+[pod123.node]
+BanDuration = "1h"
+BanThreshold = "lots"
+[pod123.nodd]
+MaxPeers = 5
+`
+	if err := os.WriteFile(cfgFile, []byte(conf), 0600); err != nil {
+		t.FailNow()
+	}
+	diags, err := ex.ValidateConfig()
+	if err != nil || len(diags) != 4 {
+		t.Fatal(diags)
+	}
+	expect := []string{
+		"config.toml:3:1: unknown option pod123 LimitUsr, did you mean LimitUser?",
+		"config.toml:4:1: pod123 Locale: invalid value 'fr'",
+		"config.toml:7:1: pod123 node BanThreshold: expected an integer",
+		"config.toml:9:1: unknown option pod123 nodd MaxPeers, did you mean table [pod123.node]?",
+	}
+	for i := range expect {
+		if !strings.Contains(diags[i].String(), expect[i]) {
+			t.Fatal(diags[i])
+		}
+	}
+	// lenient mode applies the valid values, including the quoted duration
+	if err = ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 node banduration")).String() != "1h0m0s" ||
+		ex.GetOpt(path.From("pod123 limituser")).String() != "someone" {
+		t.FailNow()
+	}
+	ex.GetOpt(path.From("pod123 strict")).FromString("true")
+	ex.GetOpt(path.From("pod123 limituser")).FromString("nobody")
+	if err = ex.LoadConfig(); err == nil {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 limituser")).String() != "nobody" {
+		t.FailNow()
+	}
+	if err = os.WriteFile(cfgFile, []byte("[pod123]\nLimitUser = \n"),
+		0600); err != nil {
+		t.FailNow()
+	}
+	if diags, _ = ex.ValidateConfig(); len(diags) != 1 || diags[0].Line < 2 {
+		t.Fatal(diags)
+	}
+}
+
+func TestCommand_ConfigCommands(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex.AddCommand(Config())
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	run := func(line string) error {
+		cmd, args, err := ex.ParseCLIArgs(strings.Split(line, " "))
+		if err != nil {
+			return err
+		}
+		return cmd.Entrypoint(ex, args)
+	}
+	if err := run("pod123 config set node rpcmaxconcurrentreqs -16"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(cfgFile)
+	if err != nil || !strings.Contains(string(b), "RPCMaxConcurrentReqs = -16") {
+		t.FailNow()
+	}
+	// values from the environment are not written into the file, and only
+	// the line of the option that is set changes
+	if err = os.WriteFile(cfgFile, append(b, "# kept\n"...), 0600); err != nil {
+		t.FailNow()
+	}
+	t.Setenv("POD123_NODE_PROXYUSER", "fromenv")
+	if err = ex.GetEnvs().Load(ProcessEnv); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if err = run("pod123 config set node rpcmaxconcurrentreqs 8"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if b, err = os.ReadFile(cfgFile); err != nil ||
+		strings.Contains(string(b), "fromenv") ||
+		!strings.Contains(string(b), "RPCMaxConcurrentReqs = 8\n# kept\n") ||
+		strings.Count(string(b), "RPCMaxConcurrentReqs =") != 1 {
+		t.Fatal(string(b))
+	}
+	if err = run("pod123 config set node banduration soon"); err == nil ||
+		!strings.Contains(err.Error(), "pod123 node banduration") {
+		t.FailNow()
+	}
+	if err = run("pod123 config unset pod123 node rpcmaxconcurrentreqs"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 node rpcmaxconcurrentreqs")).String() ==
+		"8" {
+		t.FailNow()
+	}
+	if b, err = os.ReadFile(cfgFile); err != nil ||
+		strings.Contains(string(b), "RPCMaxConcurrentReqs") ||
+		!strings.Contains(string(b), "# kept") {
+		t.Fatal(string(b))
+	}
+	if err = run("pod123 config list --tag=tls"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if err = run("pod123 config get nosuchoption"); err == nil {
+		t.FailNow()
+	}
+	if err = run("pod123 config set limituser someone"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	t.Setenv("EDITOR", "sed -i s/^LimitUser.*/LimitUser=\"edited\"/")
+	if err = run("pod123 config edit"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if b, err = os.ReadFile(cfgFile); err != nil ||
+		!strings.Contains(string(b), `LimitUser="edited"`) {
+		t.FailNow()
+	}
+	// an edit that does not validate leaves the file alone
+	t.Setenv("EDITOR", "sed -i s/^LimitUser.*/LimitUsr=1/")
+	if err = run("pod123 config edit"); err == nil {
+		t.FailNow()
+	}
+	if b, err = os.ReadFile(cfgFile); err != nil ||
+		!strings.Contains(string(b), `LimitUser="edited"`) {
+		t.FailNow()
+	}
+	// quotes and backslashes are escaped, so the file loads again
+	if err = run(`pod123 config set limituser pa"ss\w0rd`); log.E.Chk(err) {
+		t.FailNow()
+	}
+	again := GetExampleCommands()
+	GetConfigBase(again.Configs, again.Name, false)
+	again, _ = Init(again, nil)
+	if again.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	if err = again.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if v := again.GetOpt(path.From("pod123 limituser")).String(); v !=
+		`pa"ss\w0rd` {
+		t.Fatal(v)
+	}
+}
+
+func TestCommand_EnvNames(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	ex, _ := Init(GetExampleCommands(), nil)
+	if v := ex.EnvVar(path.From("pod123 node proxyuser")); v !=
+		"POD123_NODE_PROXYUSER" {
+		t.Fatal(v)
+	}
+	ex.EnvPrefix = "my-app.v2"
+	if v := ex.EnvVar(path.From("pod123 node proxyuser")); v !=
+		"MY_APP_V2_NODE_PROXYUSER" {
+		t.Fatal(v)
+	}
+	if v := EnvSanitise("2fa.key"); v != "_2FA_KEY" {
+		t.Fatal(v)
+	}
+	envs := ex.GetEnvs()
+	if err := envs.Collisions(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	t.Setenv("POD_PROXY_PASS", "fromalias")
+	if err := envs.LoadFromEnvironment(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	op := ex.GetOpt(path.From("pod123 node proxypass")).(*secret.Opt)
+	if op.Secret() != "fromalias" {
+		t.FailNow()
+	}
+	// the primary name takes precedence over the aliases
+	t.Setenv("MY_APP_V2_NODE_PROXYPASS", "primary")
+	if err := envs.LoadFromEnvironment(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if op.Secret() != "primary" {
+		t.FailNow()
+	}
+	ex.Configs["Clash"] = text.New(meta.Data{Env: "POD_PROXY_PASS"})
+	ex, _ = Init(ex, nil)
+	err := ex.GetEnvs().Collisions()
+	if err == nil || !strings.Contains(err.Error(), "POD_PROXY_PASS") {
+		t.FailNow()
+	}
+}
+
+func TestCommand_DotEnv(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	t.Setenv("TEST_DOTENV_HOME", "/home/someone")
+	envFile := dir + "/test.env"
+	data := `# comment
+export POD123_NODE_PROXYUSER='literal $${NOT}'
+POD123_NODE_ONIONPROXYUSER = plain value # trailing comment
+POD123_LIMITUSER="line1\n${POD123_NODE_PROXYUSER} \$HOME
+${TEST_DOTENV_HOME}"
+`
+	if err := os.WriteFile(envFile, []byte(data), 0600); err != nil {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 envfile")).FromString(envFile) != nil {
+		t.FailNow()
+	}
+	dot, err := ex.LoadDotEnv()
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	if dot.Values["POD123_NODE_PROXYUSER"] != "literal $${NOT}" ||
+		dot.Values["POD123_NODE_ONIONPROXYUSER"] != "plain value" ||
+		dot.Values["POD123_LIMITUSER"] !=
+			"line1\nliteral $${NOT} $HOME\n/home/someone" {
+		t.Fatal(dot.Values)
+	}
+	// the process environment is not replaced unless asked for
+	t.Setenv("POD123_NODE_PROXYUSER", "process")
+	envs := ex.GetEnvs()
+	if err = envs.Load(dot.Lookup(false)); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 node proxyuser")).String() != "process" ||
+		ex.GetOpt(path.From("pod123 node onionproxyuser")).String() !=
+			"plain value" {
+		t.FailNow()
+	}
+	for i := range envs {
+		if envs[i].Var == "POD123_NODE_ONIONPROXYUSER" &&
+			envs[i].Source != envFile+":3" {
+			t.Fatal(envs[i].Source)
+		}
+	}
+	if err = envs.Load(dot.Lookup(true)); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 node proxyuser")).String() !=
+		"literal $${NOT}" ||
+		ex.GetOpt(path.From("pod123 node proxyuser")).Expanded() !=
+			"literal ${NOT}" {
+		t.FailNow()
+	}
+	// files given explicitly must exist
+	if ex.GetOpt(path.From("pod123 envfile")).FromString(
+		dir+"/missing.env") != nil {
+		t.FailNow()
+	}
+	if _, err = ex.LoadDotEnv(); err == nil {
+		t.FailNow()
+	}
+	if err = NewDotEnv().Parse("bad.env", []byte("A=\"open\n")); err == nil ||
+		!strings.Contains(err.Error(), "bad.env:1") {
+		t.FailNow()
+	}
+	// a dotenv file cannot run commands, whatever its directory
+	for _, v := range []string{"cmd:touch x", "'CMD:touch x'"} {
+		err = NewDotEnv().Parse("cwd.env", []byte("\nA="+v+"\n"))
+		if err == nil || !strings.Contains(err.Error(),
+			"cwd.env:2: A: cmd: references are not allowed") {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCommand_UnknownEnv(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	t.Setenv("POD123_NODE_RPCMAXCONCURENTREQS", "1")
+	t.Setenv("POD123_NODE_RPCMAXCONCURRENTREQS", "1")
+	envs := ex.GetEnvs()
+	if err := ex.CheckEnv(envs, nil); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if err := ex.GetOpt(path.From("pod123 strict")).FromString("true"); err != nil {
+		t.FailNow()
+	}
+	err := ex.CheckEnv(envs, nil)
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 1 ||
+		!strings.Contains(diags[0].Message,
+			"did you mean POD123_NODE_RPCMAXCONCURRENTREQS?") {
+		t.Fatal(err)
+	}
+}
+
+func TestCommand_Profiles(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex.AddCommand(Config())
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	if err := ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(cfgFile)
+	if err != nil {
+		t.FailNow()
+	}
+	b = append(b, []byte(`
+[profile.testnet]
+LimitUser = "testnet"
+
+[profile.testnet.node]
+RPCMaxConcurrentReqs = 5
+ProxyUser = "tester"
+`)...)
+	if err = os.WriteFile(cfgFile, b, 0600); err != nil {
+		t.FailNow()
+	}
+	if err = os.MkdirAll(dir+"/profiles", 0700); err != nil {
+		t.FailNow()
+	}
+	if err = os.WriteFile(dir+"/profiles/fast.toml", []byte(`
+[pod123.node]
+RPCMaxConcurrentReqs = 50
+`), 0600); err != nil {
+		t.FailNow()
+	}
+	t.Setenv("POD123_PROFILE", "testnet,fast")
+	if err = ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex.GetOpt(path.From("pod123 limituser")).String() != "testnet" ||
+		ex.GetOpt(path.From("pod123 node proxyuser")).String() != "tester" ||
+		ex.GetOpt(path.From("pod123 node rpcmaxconcurrentreqs")).String() !=
+			"50" {
+		t.FailNow()
+	}
+	// saving keeps the profiles and does not write their values to the base
+	if err = ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if b, err = os.ReadFile(cfgFile); err != nil {
+		t.FailNow()
+	}
+	i := strings.Index(string(b), "[profile.testnet]")
+	if i < 0 || strings.Contains(string(b[:i]), "testnet") ||
+		strings.Contains(string(b[:i]), "tester") {
+		t.Fatal(string(b))
+	}
+	ex2 := GetExampleCommands()
+	GetConfigBase(ex2.Configs, ex2.Name, false)
+	ex2, _ = Init(ex2, nil)
+	if ex2.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex2.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil ||
+		ex2.GetOpt(path.From("pod123 profile")).FromString("testnet") != nil {
+		t.FailNow()
+	}
+	if err = ex2.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ex2.GetOpt(path.From("pod123 node rpcmaxconcurrentreqs")).String() !=
+		"5" {
+		t.FailNow()
+	}
+	if ex2.GetOpt(path.From("pod123 profile")).FromString("nosuch") != nil ||
+		ex2.GetOpt(path.From("pod123 strict")).FromString("true") != nil {
+		t.FailNow()
+	}
+	if err = ex2.LoadConfig(); err == nil ||
+		!strings.Contains(err.Error(), "profile nosuch not found") {
+		t.Fatal(err)
+	}
+}
+
+func TestCommand_Interpolation(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil {
+		t.FailNow()
+	}
+	lfp := ex.GetOpt(path.From("pod123 logfilepath"))
+	if lfp.Expanded() != dir+"/log.txt" ||
+		lfp.String() != "${DataDir}/log.txt" {
+		t.Fatal(lfp.Expanded())
+	}
+	// changing a referenced option updates the expanded value
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir+"/b") != nil ||
+		lfp.Expanded() != dir+"/b/log.txt" {
+		t.Fatal(lfp.Expanded())
+	}
+	t.Setenv("TEST_INTERPOLATION", "x")
+	user := ex.GetOpt(path.From("pod123 node proxyuser"))
+	if err := user.FromString(
+		"${LimitUser}-${node.OnionProxyUser}-${env:TEST_INTERPOLATION}"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	ex.GetOpt(path.From("pod123 limituser")).FromString("lu")
+	ex.GetOpt(path.From("pod123 node onionproxyuser")).FromString("${LimitUser}")
+	if user.Expanded() != "lu-lu-x" {
+		t.Fatal(user.Expanded())
+	}
+	// options referring to the option that changes are expanded again when
+	// it changes, not when they are read
+	ex.GetOpt(path.From("pod123 limituser")).FromString("lu2")
+	if user.Expanded() != "lu2-lu2-x" {
+		t.Fatal(user.Expanded())
+	}
+	var hooks int
+	ref := "a"
+	standalone := text.New(meta.Data{}, func(*text.Opt) error {
+		hooks++
+		return nil
+	})
+	standalone.SetInterpolator(func(s string) (string, error) {
+		return strings.ReplaceAll(s, "${ref}", ref), nil
+	})
+	if err := standalone.FromString("${ref}"); err != nil || hooks != 2 {
+		t.Fatal(err, hooks)
+	}
+	ref = "b"
+	if standalone.Expanded() != "a" || hooks != 2 {
+		t.Fatal(standalone.Expanded(), hooks)
+	}
+	err := ex.GetOpt(path.From("pod123 limituser")).FromString(
+		"${node.ProxyUser}")
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatal(err)
+	}
+	if err = user.FromString("${node.ProxyPass}"); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestCommand_Enum(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	ll := ex.GetOpt(path.From("pod123 loglevel"))
+	if err := ll.FromString("WARNING"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if ll.String() != "warn" {
+		t.Fatal(ll.String())
+	}
+	log2.SetLogLevel(log2.Info)
+	err := ex.GetOpt(path.From("pod123 node network")).FromString("moonnet")
+	if err == nil || !strings.Contains(err.Error(),
+		"valid choices are: mainnet, testnet, regtestnet, simnet") {
+		t.Fatal(err)
+	}
+	multi := enum.New(meta.Data{Options: Tags("a", "b", "c"), Default: "a"}).
+		Multi()
+	if err = multi.FromString("C, b,c"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if multi.String() != "c,b" || len(multi.Value().List()) != 2 {
+		t.Fatal(multi.String())
+	}
+	ex.AddCommand(&Command{Name: "multi", Configs: config.Opts{"M": multi}})
+	ex, _ = Init(ex, nil)
+	if _, diags := ex.check("test.toml", Document{"pod123": map[string]interface{}{
+		"LogLevel": "nope",
+		"multi":    map[string]interface{}{"M": []interface{}{"a", "b"}},
+	}}, positions{}); len(diags) != 1 ||
+		!strings.Contains(diags[0].Message, "valid choices are") {
+		t.Fatal(diags)
+	}
+	c := ex.Complete([]string{"node", "-network=te"})
+	if len(c) != 1 || c[0] != "-network=testnet" {
+		t.Fatal(c)
+	}
+	c = ex.Complete([]string{"-loglevel", "tr"})
+	if len(c) != 1 || c[0] != "trace" {
+		t.Fatal(c)
+	}
+	c = ex.Complete([]string{"no"})
+	if len(c) != 1 || c[0] != "node" {
+		t.Fatal(c)
+	}
+	schema, err := ex.JSONSchema()
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	var s map[string]interface{}
+	if err = json.Unmarshal(schema, &s); log.E.Chk(err) ||
+		!strings.Contains(string(schema), `"regtestnet"`) {
+		t.FailNow()
+	}
+}
+
+func TestCommand_Map(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	pw := ex.GetOpt(path.From("pod123 node peerweights"))
+	_, _, err := ex.ParseCLIArgs(strings.Split("pod123 node "+
+		"-pwt 10.0.0.1=5 --peerweights=10.0.0.2=7,10.0.0.3=1", " "))
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	if pw.String() != "10.0.0.1=5,10.0.0.2=7,10.0.0.3=1" ||
+		pw.Value().Map()["10.0.0.2"] != "7" {
+		t.Fatal(pw.String())
+	}
+	if err = pw.FromString("a=b"); err == nil ||
+		!strings.Contains(err.Error(), "not integer") {
+		t.Fatal(err)
+	}
+	t.Setenv("POD123_NODE_PEERWEIGHTS", "x=1,y=2")
+	if err = ex.GetEnvs().LoadFromEnvironment(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if pw.String() != "x=1,y=2" {
+		t.Fatal(pw.String())
+	}
+	if err = ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(cfgFile)
+	if err != nil || !strings.Contains(string(b),
+		`PeerWeights = { x = 1, y = 2 }`) {
+		t.Fatal(string(b))
+	}
+	b = []byte(strings.Replace(string(b), `PeerWeights = { x = 1, y = 2 }`,
+		"", 1) + "\n[pod123.node.PeerWeights]\n\"z.z\" = 3\n")
+	if err = os.WriteFile(cfgFile, b, 0600); err != nil {
+		t.FailNow()
+	}
+	if err = ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if pw.String() != "z.z=3" {
+		t.Fatal(pw.String())
+	}
+	// a value holding a comma is quoted, and saved whole
+	hd := mapopt.New(meta.Data{})
+	err = hd.FromString(`"Accept=text/html, application/json",X=1`)
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	v, err := tomlValue(hd, hd.String())
+	if err != nil ||
+		v != `{ Accept = "text/html, application/json", X = "1" }` {
+		t.Fatal(v, err)
+	}
+	if _, err = tomlValue(hd, "novalue"); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestCommand_Size(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	dbc := ex.GetOpt(path.From("pod123 node dbcache"))
+	for in, bytes := range map[string]int64{
+		"512MiB":      512 << 20,
+		"1.5GB":       1500000000,
+		"64M":         64000000,
+		"0x1000_0000": 1 << 28,
+		"1gi":         1 << 30,
+		"1k":          4 << 20,
+		"1TB":         64 << 30,
+	} {
+		if err := dbc.FromString(in); log.E.Chk(err) {
+			t.FailNow()
+		}
+		if dbc.Value().Integer() != bytes {
+			t.Fatal(in, dbc.Value().Integer())
+		}
+	}
+	if err := dbc.FromString("12 parsecs"); err == nil {
+		t.Fatal("expected an error for an unknown unit")
+	}
+	if err := dbc.FromString("1.5GB"); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if dbc.String() != "1.5GB" {
+		t.Fatal(dbc.String())
+	}
+	if err := ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(cfgFile)
+	if err != nil || !strings.Contains(string(b), `DbCache = "1.5GB"`) {
+		t.Fatal(string(b))
+	}
+	b = []byte(strings.Replace(string(b), `DbCache = "1.5GB"`,
+		`DbCache = 1073741824`, 1))
+	if err = os.WriteFile(cfgFile, b, 0600); err != nil {
+		t.FailNow()
+	}
+	if err = ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if dbc.String() != "1GiB" {
+		t.Fatal(dbc.String())
+	}
+	n := integer.New(meta.Data{Default: "4Ki"}).Units()
+	if n.Value().Integer() != 4096 || n.String() != "4Ki" {
+		t.Fatal(n.String())
+	}
+	if err = n.FromString("1_000_000"); err != nil || n.String() != "1M" {
+		t.Fatal(err, n.String())
+	}
+	f := float.New(meta.Data{Default: "250m"}).Units()
+	if f.Value().Float() != 0.25 || f.String() != "0.25" {
+		t.Fatal(f.String())
+	}
+	if err = f.FromString("1.5k"); err != nil || f.String() != "1.5k" {
+		t.Fatal(err, f.String())
+	}
+}
+
+func TestCommand_Network(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	_, _, err := ex.ParseCLIArgs(strings.Split("pod123 node "+
+		"--la=0.0.0.0,[fe80::1%eth0]:9000,unix:/tmp/pod.sock,if:lo "+
+		"--wl=10.0.0.0/8,192.168.1.7 --feu=https://fees.example.com/v1",
+		" "))
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	la := ex.GetOpt(path.From("pod123 node p2plisteners")).(*endpoint.Opt)
+	eps := la.Endpoints()
+	if len(eps) != 4 || eps[0].Address() != "0.0.0.0:11047" ||
+		eps[1].Zone != "eth0" || eps[1].Address() != "[fe80::1%eth0]:9000" ||
+		eps[2].Network != "unix" || eps[2].Address() != "/tmp/pod.sock" ||
+		eps[3].Interface != "lo" {
+		t.Fatal(la.String())
+	}
+	if x, e := la.Expand(); e != nil || len(x) < 4 {
+		t.Log("no loopback interface to expand", e)
+	}
+	wl := ex.GetOpt(path.From("pod123 node whitelists")).(*cidr.Opt)
+	if !wl.Contains(net.ParseIP("10.1.2.3")) ||
+		!wl.Contains(net.ParseIP("192.168.1.7")) ||
+		wl.Contains(net.ParseIP("192.168.1.8")) ||
+		wl.String() != "10.0.0.0/8,192.168.1.7/32" {
+		t.Fatal(wl.String())
+	}
+	feu := ex.GetOpt(path.From("pod123 node feeestimatorurl")).(*urlopt.Opt)
+	if feu.URL().Host != "fees.example.com" {
+		t.Fatal(feu.String())
+	}
+	for op, bad := range map[config.Option][2]string{
+		la:  {"127.0.0.1:99999", "invalid port '99999'"},
+		wl:  {"10.0.0.0/33", "'/33' in network '10.0.0.0/33'"},
+		feu: {"ftp://example.com", "scheme 'ftp'"},
+		ex.GetOpt(path.From("pod123 node rpcconnect")): {"[::1:11048",
+			"missing ']'"},
+		ex.GetOpt(path.From("pod123 node proxyaddress")): {"proxy.local",
+			"missing port"},
+		ip.New(meta.Data{}): {"10.0.0.1:80", "has a port"},
+	} {
+		if e := op.FromString(bad[0]); e == nil ||
+			!strings.Contains(e.Error(), bad[1]) {
+			t.Fatal(bad[0], e)
+		}
+	}
+	if err = ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(cfgFile)
+	if err != nil || !strings.Contains(string(b),
+		`Whitelists = [ "10.0.0.0/8", "192.168.1.7/32" ]`) {
+		t.Fatal(string(b))
+	}
+	b = []byte(strings.Replace(string(b),
+		`Whitelists = [ "10.0.0.0/8", "192.168.1.7/32" ]`,
+		`Whitelists = [ "fd00::/8" ]`, 1))
+	if err = os.WriteFile(cfgFile, b, 0600); err != nil {
+		t.FailNow()
+	}
+	if err = ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if !wl.Contains(net.ParseIP("fd12::1")) || la.Value().List()[3] !=
+		"if:lo:11047" {
+		t.Fatal(wl.String(), la.String())
+	}
+}
+
+func TestCommand_Typed(t *testing.T) {
+	d := duration.New(meta.Data{Default: "1h"},
+		duration.Clamp(time.Second, time.Minute))
+	n := integer.New(meta.Data{Default: "100"}, integer.Clamp(1, 10))
+	f := float.New(meta.Data{Default: "-1"}, float.Clamp(0, 1))
+	if d.Value().Duration() != time.Minute || n.Value().Integer() != 10 ||
+		f.Value().Float() != 0 {
+		t.Fatal(d, n, f)
+	}
+	if err := d.FromString("10ms"); err != nil || d.String() != "1s" {
+		t.Fatal(err, d)
+	}
+	// a new option type needs only its parser, formatter and accessor
+	hex := opts.NewTyped(meta.New(meta.Data{}, meta.Integer),
+		func(s string) (int64, error) { return strconv.ParseInt(s, 16, 64) },
+		func(v int64) string { return strconv.FormatInt(v, 16) },
+		func(c *config.Concrete, v func() int64) { c.Integer = v })
+	var op config.Option = hex
+	if err := op.FromString(" ff "); err != nil || op.String() != "ff" ||
+		op.Value().Integer() != 255 || op.Value().Text() != "" {
+		t.Fatal(err, op)
+	}
+	if err := op.FromString("fg"); err == nil || hex.Load() != 255 {
+		t.Fatal("expected an error leaving the value unchanged")
+	}
+}
+
+// captureStdout returns what a function prints to standard output.
+func captureStdout(t *testing.T, fn func() error) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- b
+	}()
+	err = fn()
+	os.Stdout = stdout
+	_ = w.Close()
+	out := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestCommand_Validators(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	ex.AddCommand(Help())
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	mp := ex.GetOpt(path.From("pod123 node maxpeers"))
+	if err := mp.FromString("5000"); err != nil ||
+		mp.Value().Integer() != 1000 {
+		t.Fatal(err, mp)
+	}
+	mp.(*integer.Opt).FromValue(-1)
+	if mp.Value().Integer() != 0 {
+		t.Fatal(mp)
+	}
+	bd := ex.GetOpt(path.From("pod123 node banduration"))
+	if err := bd.FromString("1ms"); err == nil ||
+		!strings.Contains(err.Error(), "below the minimum of 1s") ||
+		bd.String() != "24h0m0s" {
+		t.Fatal(err, bd)
+	}
+	ua := ex.GetOpt(path.From("pod123 node useragentcomments"))
+	if err := ua.FromString("fine,not/fine"); err == nil ||
+		!strings.Contains(err.Error(), "'not/fine' does not match") {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfgFile, []byte("[pod123.node]\n"+
+		"BanDuration = \"1ms\"\nMaxPeers = 2000\n"), 0600); err != nil {
+		t.FailNow()
+	}
+	diags, err := ex.ValidateConfig()
+	if err != nil || len(diags) != 1 || !strings.Contains(diags[0].String(),
+		"config.toml:2:1: pod123 node BanDuration: 1ms is below the minimum") {
+		t.Fatal(err, diags)
+	}
+	if err = ex.LoadConfig(); log.E.Chk(err) || mp.Value().Integer() != 1000 {
+		t.Fatal(err, mp)
+	}
+	out := captureStdout(t, func() error {
+		return HelpEntrypoint(ex, []string{"maxpeers"})
+	})
+	if !strings.Contains(out, "Validation:\n\n\tfrom 0 to 1000") {
+		t.Fatal(out)
+	}
+	schema, err := ex.JSONSchema()
+	if err != nil {
+		t.FailNow()
+	}
+	var s struct {
+		Properties map[string]struct {
+			Properties map[string]struct {
+				Properties map[string]map[string]interface{}
+			}
+		}
+	}
+	if err = json.Unmarshal(schema, &s); err != nil {
+		t.Fatal(err)
+	}
+	node := s.Properties["pod123"].Properties["node"].Properties
+	if node["MaxPeers"]["maximum"] != 1000.0 ||
+		node["UserAgentComments"]["items"].(map[string]interface{})["pattern"] !=
+			`^[^/:()]*$` ||
+		fmt.Sprint(node["BanDuration"]["x-validators"]) != "[at least 1s]" {
+		t.Fatal(node["MaxPeers"], node["UserAgentComments"],
+			node["BanDuration"])
+	}
+	file := dir + "/file"
+	if err = os.WriteFile(file, nil, 0600); err != nil {
+		t.FailNow()
+	}
+	even := meta.Custom("even", func(v interface{}) error {
+		if v.(int64)%2 != 0 {
+			return fmt.Errorf("%d is odd", v)
+		}
+		return nil
+	})
+	for _, c := range []struct {
+		op       config.Option
+		good     string
+		bad, err string
+	}{
+		{text.New(meta.Data{Validators: meta.Validators(
+			meta.Length(2, 4))}), "abc", "abcde", "length 5 is more than 4"},
+		{text.New(meta.Data{Validators: meta.Validators(
+			meta.OneOf("a", "b"))}), "b", "c", "'c' is not one of: a, b"},
+		{text.New(meta.Data{Validators: meta.Validators(
+			meta.PathExists())}), file, dir + "/nope", "does not exist"},
+		{text.New(meta.Data{Validators: meta.Validators(
+			meta.IsDir())}), dir, file, "is not a directory"},
+		{text.New(meta.Data{Validators: meta.Validators(
+			meta.WritableDir())}), dir, dir + "/nope", "does not exist"},
+		{integer.New(meta.Data{Validators: meta.Validators(even)}),
+			"4", "3", "3 is odd"},
+		{secret.New(meta.Data{Validators: meta.Validators(
+			meta.Length(8, 0))}), "longenough", "short",
+			"it must be length at least 8"},
+	} {
+		if err = c.op.FromString(c.good); err != nil {
+			t.Fatal(c.good, err)
+		}
+		if err = c.op.FromString(c.bad); err == nil ||
+			!strings.Contains(err.Error(), c.err) ||
+			strings.Contains(err.Error(), "short") {
+			t.Fatal(c.bad, err)
+		}
+	}
+	// the value is checked with references resolved and expanded
+	exists := text.New(meta.Data{Validators: meta.Validators(
+		meta.PathExists())})
+	exists.SetInterpolator(func(s string) (string, error) {
+		return strings.ReplaceAll(s, "${dir}", dir), nil
+	})
+	if err = exists.FromString("${dir}/file"); err != nil ||
+		exists.Check("${dir}/nope") == nil {
+		t.Fatal(err)
+	}
+	short := dir + "/short"
+	if err = os.WriteFile(short, []byte("short\n"), 0600); err != nil {
+		t.FailNow()
+	}
+	sec := secret.New(meta.Data{Validators: meta.Validators(
+		meta.Length(8, 0))})
+	if err = sec.FromString("file:" + short); err == nil ||
+		sec.Check("file:"+short) == nil || sec.Raw() != "" {
+		t.Fatal(err)
+	}
+	// a reference is resolved once each time it is set, even when it was
+	// checked first as the configuration file is
+	var resolved int
+	opts.Register("validatortest", func(ref string) (string, error) {
+		resolved++
+		return ref, nil
+	})
+	if err = sec.FromString("validatortest:longenough"); err != nil ||
+		resolved != 1 || sec.Secret() != "longenough" {
+		t.Fatal(err, resolved)
+	}
+	if sec.Check("validatortest:password") != nil ||
+		sec.FromString("validatortest:password") != nil || resolved != 2 {
+		t.Fatal(resolved)
+	}
+	if sec.FromString("validatortest:password") != nil || resolved != 3 {
+		t.Fatal(resolved)
+	}
+	// a clamped range fixes each item of a slice
+	m := meta.New(meta.Data{Validators: meta.Validators(
+		meta.Range(1, 10).Clamped())}, meta.List)
+	if v, err := m.Validate([]int64{0, 5, 20}); err != nil ||
+		fmt.Sprint(v) != "[1 5 10]" || m.Check([]int64{20}) != nil {
+		t.Fatal(v, err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen", err)
+	}
+	defer l.Close()
+	port := integer.New(meta.Data{Validators: meta.Validators(
+		meta.PortAvailable())})
+	if err = port.FromString(fmt.Sprint(l.Addr().(*net.TCPAddr).Port)); err == nil {
+		t.Fatal("expected the port in use to be rejected")
+	}
+}
+
+func TestCommand_Subscribe(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	changes, cancel := ex.Watch(path.From("pod123 node"))
+	var calls []string
+	var mx sync.Mutex
+	called := make(chan struct{}, 10)
+	unsubscribe := ex.Subscribe(path.From("pod123 Node P2PListeners"),
+		func(old, new string) {
+			mx.Lock()
+			calls = append(calls, old+" > "+new)
+			mx.Unlock()
+			called <- struct{}{}
+		})
+	la := ex.GetOpt(path.From("pod123 node p2plisteners"))
+	mp := ex.GetOpt(path.From("pod123 node maxpeers"))
+	old := la.String()
+	if la.FromString("127.0.0.1") != nil || mp.FromString("100") != nil ||
+		mp.FromString("100") != nil {
+		t.FailNow()
+	}
+	for _, want := range []Change{
+		{path.From("pod123 node P2PListeners"), old, "127.0.0.1:11047"},
+		{path.From("pod123 node MaxPeers"), "25", "100"},
+	} {
+		select {
+		case chs := <-changes:
+			if ch := chs[0]; len(chs) != 1 || !ch.Path.Equal(want.Path) || ch.Old != want.Old ||
+				ch.New != want.New {
+				t.Fatal(chs)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no change received for", want.Path)
+		}
+	}
+	<-called
+	unsubscribe()
+	if la.FromString("127.0.0.2") != nil {
+		t.FailNow()
+	}
+	<-changes
+	mx.Lock()
+	if len(calls) != 1 || calls[0] != old+" > 127.0.0.1:11047" {
+		t.Fatal(calls)
+	}
+	mx.Unlock()
+	cancel()
+	if _, ok := <-changes; ok {
+		t.Fatal("channel not closed")
+	}
+	// secrets are redacted
+	redacted := make(chan string, 1)
+	defer ex.Subscribe(path.From("pod123 configpassphrase"),
+		func(old, new string) { redacted <- old + new })()
+	ex.GetOpt(path.From("pod123 configpassphrase")).FromString("hunter2")
+	if s := <-redacted; s != secret.Redacted+secret.Redacted {
+		t.Fatal(s)
+	}
+	// changes from many goroutines are delivered one at a time
+	var busy, overlap, n atomic.Int32
+	done := make(chan struct{})
+	defer ex.SubscribeChanges(path.From("pod123"), func(chs []Change) {
+		if busy.Inc() > 1 {
+			overlap.Inc()
+		}
+		time.Sleep(time.Millisecond)
+		busy.Dec()
+		if n.Add(int32(len(chs))) == 20 {
+			close(done)
+		}
+	})()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			op := ex.GetOpt(path.From("pod123 node maxpeers"))
+			if i%2 == 1 {
+				op = ex.GetOpt(path.From("pod123 node banthreshold"))
+			}
+			log.E.Chk(op.FromString(strconv.Itoa(200 + i)))
+		}(i)
+	}
+	wg.Wait()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("received", n.Load(), "of 20 changes")
+	}
+	if overlap.Load() > 0 {
+		t.Fatal("changes delivered concurrently")
+	}
+}
+
+func TestCommand_Update(t *testing.T) {
+	var ran []string
+	hook := func(o *integer.Opt) (err error) {
+		ran = append(ran, o.String())
+		if o.Value().Integer() == 13 {
+			err = errors.New("unlucky")
+		}
+		return
+	}
+	c, _ := Init(&Command{Name: "app", Configs: config.Opts{
+		"A": integer.New(meta.Data{Default: "1"}, hook),
+		"B": integer.New(meta.Data{Default: "2",
+			Validators: meta.Validators(meta.Max(100))}, hook),
+		"S": secret.New(meta.Data{}),
+	}}, nil)
+	a, b := c.GetOpt(path.From("app a")), c.GetOpt(path.From("app b"))
+	changes, cancel := c.Watch(path.From("app"))
+	defer cancel()
+	ran = nil
+	err := c.Update(func(tx *Tx) error {
+		_ = tx.Set(path.From("app a"), "5")
+		return tx.Set(path.From("app b"), "500")
+	})
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 1 ||
+		!strings.Contains(err.Error(), "above the maximum") ||
+		a.String() != "1" || len(ran) != 0 {
+		t.Fatal(err, a, ran)
+	}
+	if c.Update(func(tx *Tx) error {
+		return tx.Set(path.From("app c"), "1")
+	}) == nil {
+		t.Fatal("set an option that does not exist")
+	}
+	err = c.Update(func(tx *Tx) error {
+		_ = tx.Set(path.From("app b"), "7")
+		_ = tx.Set(path.From("app a"), "13")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "unlucky") ||
+		a.String() != "1" || b.String() != "2" ||
+		strings.Join(ran, " ") != "7 13 1 2" {
+		t.Fatal(err, a, b, ran)
+	}
+	err = c.Update(func(tx *Tx) error {
+		_ = tx.Set(path.From("app B"), "3")
+		_ = tx.Set(path.From("app S"), "hunter2")
+		_ = tx.Set(path.From("app a"), "9")
+		if v, _ := tx.Get(path.From("app b")); v != "3" {
+			t.Error("staged value not returned", v)
+		}
+		return tx.Set(path.From("app b"), "4")
+	})
+	if log.E.Chk(err) || a.String() != "9" || b.String() != "4" {
+		t.Fatal(a, b)
+	}
+	select {
+	case chs := <-changes:
+		if fmt.Sprint(chs) != "[{app B 2 4} {app S "+secret.Redacted+" "+
+			secret.Redacted+"} {app A 1 9}]" {
+			t.Fatal(chs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no changes received")
+	}
+}
+
+func TestCommand_Snapshot(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex.AddCommand(Config())
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	mp := ex.GetOpt(path.From("pod123 node maxpeers"))
+	pw := ex.GetOpt(path.From("pod123 password")).(*secret.Opt).
+		SaveAs(secret.SaveValue)
+	before, old := ex.Snapshot(), pw.Secret()
+	if mp.FromString("100") != nil || pw.FromString("hunter2") != nil {
+		t.FailNow()
+	}
+	after := ex.Snapshot()
+	want := "~ pod123 node MaxPeers: 25 -> 100\n~ pod123 Password: " +
+		secret.Redacted
+	if d := before.Diff(after); !strings.HasPrefix(d.String(), want) ||
+		len(d) != 2 {
+		t.Fatal(d)
+	}
+	if v, ok := after.Get(path.From("pod123 node MAXPEERS")); !ok ||
+		v != "100" {
+		t.Fatal(v)
+	}
+	if err := ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if err := ex.Restore(before); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if mp.String() != "25" || pw.Secret() != old ||
+		len(before.Diff(ex.Snapshot())) != 0 {
+		t.Fatal(mp, pw.Secret())
+	}
+	diff := ex.GetCommand("pod123 config diff")
+	out := captureStdout(t, func() error {
+		return diff.Entrypoint(ex, []string{cfgFile})
+	})
+	if !strings.Contains(out, "~ pod123 node MaxPeers: 25 -> 100\n") ||
+		!strings.Contains(out, "~ pod123 Password: "+secret.Redacted) ||
+		strings.Contains(out, "hunter2") {
+		t.Fatal(out)
+	}
+	other := dir + "/other.toml"
+	b, _ := os.ReadFile(cfgFile)
+	b = []byte(strings.Replace(string(b), "MaxPeers = 100",
+		"MaxPeers = 50", 1))
+	if os.WriteFile(other, b, 0600) != nil {
+		t.FailNow()
+	}
+	out = captureStdout(t, func() error {
+		return diff.Entrypoint(ex, []string{cfgFile, other})
+	})
+	if out != "~ pod123 node MaxPeers: 100 -> 50\n" {
+		t.Fatal(out)
+	}
+	if mp.String() != "25" || len(before.Diff(ex.Snapshot())) != 0 {
+		t.Fatal("diff changed the options", mp)
+	}
+	// the files are evaluated apart from the options, whose references are
+	// not resolved again
+	var resolved int
+	opts.Register("snapshottest", func(ref string) (string, error) {
+		resolved++
+		return ref, nil
+	})
+	lu := ex.GetOpt(path.From("pod123 limituser"))
+	if lu.FromString("snapshottest:live") != nil || resolved != 1 {
+		t.FailNow()
+	}
+	b = []byte(strings.NewReplacer("MaxPeers = 50", "MaxPeers = 60",
+		"LimitUser = \"", "LimitUser = \"snapshottest:").Replace(string(b)))
+	if os.WriteFile(other, b, 0600) != nil {
+		t.FailNow()
+	}
+	out = captureStdout(t, func() error {
+		return diff.Entrypoint(ex, []string{cfgFile, other})
+	})
+	if !strings.Contains(out, "~ pod123 node MaxPeers: 100 -> 60\n") ||
+		!strings.Contains(out, "-> snapshottest:") || resolved != 1 ||
+		lu.Expanded() != "live" {
+		t.Fatal(out, resolved, lu.Expanded())
+	}
+}
+
+func TestCommand_List(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	dir := t.TempDir()
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex, _ = Init(ex, nil)
+	cfgFile := dir + "/config.toml"
+	if ex.GetOpt(path.From("pod123 datadir")).FromString(dir) != nil ||
+		ex.GetOpt(path.From("pod123 configfile")).FromString(cfgFile) != nil {
+		t.FailNow()
+	}
+	ap := ex.GetOpt(path.From("pod123 node addpeers")).(*list.Opt)
+	if ap.String() != "" || len(ap.Items()) != 0 {
+		t.Fatal(ap.Items())
+	}
+	want := []string{"a", "b,c", `say "hi"`, " spaced ", ""}
+	in := `a,"b,c","say ""hi"""," spaced ",""`
+	_, _, err := ex.ParseCLIArgs([]string{"pod123", "node", "--ap=" + in})
+	if log.E.Chk(err) {
+		t.FailNow()
+	}
+	if fmt.Sprintf("%q", ap.Items()) != fmt.Sprintf("%q", want) ||
+		ap.String() != in {
+		t.Fatalf("%q %s", ap.Items(), ap.String())
+	}
+	t.Setenv("POD123_NODE_ADDPEERS", `"x,y",z`)
+	if err = ex.GetEnvs().LoadFromEnvironment(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if items := ap.Items(); len(items) != 2 || items[0] != "x,y" {
+		t.Fatalf("%q", items)
+	}
+	for bad, msg := range map[string]string{
+		`"a`:   "unterminated quote",
+		`a"b`:  "quote inside the unquoted item",
+		`"a"b`: "after the quoted item",
+	} {
+		if e := ap.FromString(bad); e == nil ||
+			!strings.Contains(e.Error(), msg) {
+			t.Fatal(bad, e)
+		}
+	}
+	ap.FromValue(want)
+	if err = ex.SaveConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	b, err := os.ReadFile(cfgFile)
+	if err != nil || !strings.Contains(string(b),
+		`AddPeers = [ "a", "b,c", "say \"hi\"", " spaced ", "" ]`) ||
+		!strings.Contains(string(b), "AddCheckpoints = []") {
+		t.Fatal(string(b))
+	}
+	ap.FromValue(nil)
+	if err = ex.LoadConfig(); log.E.Chk(err) {
+		t.FailNow()
+	}
+	if fmt.Sprintf("%q", ap.Items()) != fmt.Sprintf("%q", want) {
+		t.Fatalf("%q", ap.Items())
+	}
+	paths := list.New(meta.Data{Default: "/usr/bin:/bin"}).Separator(':')
+	if items := paths.Items(); len(items) != 2 || items[1] != "/bin" {
+		t.Fatalf("%q", items)
+	}
+	paths.FromValue([]string{`C:\bin`, "/opt"})
+	if paths.String() != `"C:\bin":/opt` {
+		t.Fatal(paths.String())
+	}
+}
+
+func TestCommand_Tags(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex.AddCommand(Config())
+	ex.AddCommand(Help())
+	ex, _ = Init(ex, nil)
+	for _, bad := range []string{"node &&", "(node", "node tls", "&& x",
+		"node & tls"} {
+		if _, err := ParseTagQuery(bad); err == nil {
+			t.Fatal("parsed", bad)
+		}
+	}
+	tls, err := ex.SelectTags("TLS")
+	if err != nil || len(tls) < 1 || !tls[0].Path.Equal(
+		path.From("pod123 CAFile")) {
+		t.Fatal(err, tls)
+	}
+	sel, _ := ex.SelectTags("(node || wallet) && !tls")
+	for _, s := range sel {
+		if q, _ := ParseTagQuery("tls"); q.Match(s.Meta().Tags()) {
+			t.Fatal(s.Path)
+		}
+	}
+	if all, _ := ex.SelectTags("node || wallet"); len(sel) == 0 ||
+		len(all) != len(sel)+len(tls) {
+		t.Fatal(len(all), len(sel), len(tls))
+	}
+	counts := ex.TagCounts()
+	found := false
+	for _, c := range counts {
+		if c.Tag == "tls" && c.Count == len(tls) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal(counts)
+	}
+	run := func(p string, args ...string) string {
+		return captureStdout(t, func() error {
+			return ex.GetCommand(p).Entrypoint(ex, args)
+		})
+	}
+	out := run("pod123 config tags")
+	if !strings.Contains(out, fmt.Sprintf("tls %d\n", len(tls))) {
+		t.Fatal(out)
+	}
+	out = run("pod123 config list", "--tag", "wallet && !node")
+	if strings.Count(out, "\n") < 1 || strings.Contains(out, "CAFile") {
+		t.Fatal(out)
+	}
+	out = run("pod123 config export", "--tag=tls")
+	if !strings.Contains(out, "CAFile = ") ||
+		strings.Contains(out, "MaxPeers") {
+		t.Fatal(out)
+	}
+	if err = ex.UnmarshalText([]byte(out)); log.E.Chk(err) {
+		t.FailNow()
+	}
+	out = run("pod123 config export", "--env", "--tag=tls")
+	if !strings.Contains(out, "POD123_CAFILE=") ||
+		strings.Contains(out, "MAXPEERS") {
+		t.Fatal(out)
+	}
+	out = run("pod123 help", "--tag=tls", "ca")
+	if !strings.Contains(out, "-cafile=") ||
+		strings.Contains(out, "-rpccert") {
+		t.Fatal(out)
+	}
+}
+
+func TestCommand_Sections(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	ex := GetExampleCommands()
+	GetConfigBase(ex.Configs, ex.Name, false)
+	ex.AddCommand(Config())
+	ex.AddCommand(Help())
+	ex, _ = Init(ex, nil)
+	names := func(s []Section) (n []string) {
+		for i := range s {
+			n = append(n, s[i].Name)
+		}
+		return
+	}
+	opts := ex.OptionSections()
+	if got := fmt.Sprint(names(opts)); got != "[node wallet ctl "+
+		"Configuration Environment Logging Other]" {
+		t.Fatal(got)
+	}
+	if got := fmt.Sprint(opts[3].Names[:2]); got != "[ConfigFile DataDir]" {
+		t.Fatal(got)
+	}
+	cmds := ex.CommandSections()
+	if got := fmt.Sprint(cmds); got != "[{Servers [node wallet kopach "+
+		"worker]} {Clients [ctl gui]} {Other [config help version]}]" {
+		t.Fatal(got)
+	}
+	// nothing grouped is one section with no heading
+	if s := ex.GetCommand("pod123 config").CommandSections(); len(s) != 1 ||
+		s[0].Name != "" {
+		t.Fatal(s)
+	}
+	help := func(args ...string) string {
+		return captureStdout(t, func() error {
+			return HelpEntrypoint(ex, append([]string{}, args...))
+		})
+	}
+	top := help()
+	if strings.Index(top, "\tServers:\n") > strings.Index(top,
+		"\tClients:\n") || !strings.Contains(top, "\tLogging:\n") {
+		t.Fatal(top)
+	}
+	node := help("node")
+	if !strings.Contains(node, "-addcheckpoints") ||
+		strings.Contains(node, "-configfile") ||
+		!strings.Contains(node, "\tIndexes:\n\tdropindexes ") {
+		t.Fatal(node)
+	}
+	for i := 0; i < 10; i++ {
+		if help() != top || help("node") != node {
+			t.Fatal("help output changed between runs")
+		}
+	}
+}
+
+func TestCommand_ManPages(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	tree := func() *Command {
+		ex := GetExampleCommands()
+		GetConfigBase(ex.Configs, ex.Name, false)
+		ex.AddCommand(Config())
+		ex.AddCommand(Help())
+		ex.AddCommand(GenMan())
+		ex, _ = Init(ex, nil)
+		return ex
+	}
+	ex := tree()
+	pages := ex.ManPages()
+	byName := make(map[string]string)
+	for _, p := range pages {
+		byName[p.Name] = string(p.Text)
+	}
+	if pages[0].Name != "pod123.1" || byName["pod123-node-dropaddrindex.1"] ==
+		"" || byName["pod123-gen-man.1"] != "" {
+		t.Fatal(len(pages), pages[0].Name)
+	}
+	// a separate tree gives the same pages, whatever the order of its maps
+	again := tree().ManPages()
+	for i := range pages {
+		if again[i].Name != pages[i].Name ||
+			!bytes.Equal(again[i].Text, pages[i].Text) {
+			t.Fatal("man page changed:", pages[i].Name)
+		}
+	}
+	root := byName["pod123.1"]
+	for _, want := range []string{
+		".TH \"POD123\" \"1\"",
+		".SH NAME\npod123 \\- All in one everything for parallelcoin\n",
+		".SS Servers\n.TP\n.B node\n",
+		"Without a subcommand, \\fBgui\\fR is run.",
+		"\\fB\\-cafile\\fR, \\fB\\-ca\\fR=\\fIText\\fR\n",
+		"Environment: POD123_CAFILE\n",
+		"Choices: off, fatal",
+		".BR pod123\\-node (1),\n",
+	} {
+		if !strings.Contains(root, want) {
+			t.Fatal("root page does not have", want)
+		}
+	}
+	if strings.Contains(root, "gen\\-man") {
+		t.Fatal("hidden command in root page")
+	}
+	set := byName["pod123-config-set.1"]
+	if !strings.Contains(set, ".B pod123 config set\n\\fI<path>\\fR\n"+
+		"\\fI<value>\\fR\n") || !strings.Contains(set,
+		".SH SEE ALSO\n.BR pod123\\-config (1)\n") {
+		t.Fatal(set)
+	}
+	// indented documentation is kept as written
+	if !strings.Contains(root, ".RS\n.nf\n") {
+		t.Fatal("no preformatted paragraph")
+	}
+	for _, c := range ex.Complete([]string{"ge"}) {
+		if c == "gen-man" {
+			t.Fatal("hidden command completed")
+		}
+	}
+	dir := t.TempDir()
+	out := captureStdout(t, func() error {
+		return ex.GetCommand("pod123 gen-man").Entrypoint(ex,
+			[]string{dir})
+	})
+	if strings.Count(out, "\n") != len(pages) {
+		t.Fatal(out)
+	}
+	text, err := os.ReadFile(filepath.Join(dir, "pod123-config-set.1"))
+	if err != nil || string(text) != set {
+		t.Fatal(err)
+	}
+}
+
+func TestCommand_Docs(t *testing.T) {
+	log2.SetLogLevel(log2.Info)
+	tree := func() *Command {
+		ex := GetExampleCommands()
+		GetConfigBase(ex.Configs, ex.Name, false)
+		ex.AddCommand(Config())
+		ex.AddCommand(Help())
+		ex.AddCommand(GenMan())
+		ex, _ = Init(ex, nil)
+		return ex
+	}
+	ex := tree()
+	pages := ex.MarkdownPages()
+	if pages[0].Name != "pod123.md" || len(pages) != len(ex.ManPages()) {
+		t.Fatal(len(pages), pages[0].Name)
+	}
+	node := string(ex.GetCommand("pod123 node").Markdown())
+	for _, want := range []string{
+		"# pod123 node\n\nParallelCoin blockchain node\n\n" +
+			"Part of [pod123](pod123.md).\n",
+		"### Indexes\n\n| Command | Description |\n| --- | --- |\n" +
+			"| [pod123 node dropindexes](pod123-node-dropindexes.md) |",
+		"| [`-maxpeers`](#pod123-node--maxpeers) | `-mp` | Integer | `25` " +
+			"| `POD123_NODE_MAXPEERS` | `pod123.node.MaxPeers` | node |\n",
+		"<a id=\"pod123-node--maxpeers\"></a>\n#### MaxPeers\n",
+		"## Example configuration\n\n```toml\n[pod123.node]\n",
+		"\nMaxPeers = 25\n",
+	} {
+		if !strings.Contains(node, want) {
+			t.Fatal("node page does not have", want)
+		}
+	}
+	set := string(ex.GetCommand("pod123 config set").Markdown())
+	if !strings.Contains(set, "```\npod123 config set <path> <value>\n```") {
+		t.Fatal(set)
+	}
+	// the examples together are a configuration file
+	var conf []byte
+	for _, cm := range ex.docCommands() {
+		conf = append(conf, cm.ExampleConfig()...)
+	}
+	if err := ex.UnmarshalText(conf); log.E.Chk(err) {
+		t.Fatal(string(conf))
+	}
+	// every link is to a page that is written
+	dir := t.TempDir()
+	files, err := ex.WriteMarkdown(dir)
+	if err != nil || len(files) != len(pages) {
+		t.Fatal(err, len(files))
+	}
+	mdLink := regexp.MustCompile(`\]\(([^)#]+\.md)\)`)
+	for _, p := range pages {
+		for _, m := range mdLink.FindAllStringSubmatch(string(p.Text), -1) {
+			if _, err = os.Stat(filepath.Join(dir, m[1])); err != nil {
+				t.Fatal(p.Name, "links to missing", m[1])
+			}
+		}
+	}
+	page := string(ex.HTML())
+	if page != string(tree().HTML()) {
+		t.Fatal("html changed between trees")
+	}
+	for _, want := range []string{
+		"<section id=\"pod123-node\">",
+		"<dt id=\"pod123-node--maxpeers\">",
+		"<pre>pod123 config set &lt;path&gt; &lt;value&gt;</pre>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Fatal("html does not have", want)
+		}
+	}
+	if strings.Contains(page, "gen-man") {
+		t.Fatal("hidden command documented")
+	}
+	ids := make(map[string]bool)
+	for _, m := range regexp.MustCompile(`id="([^"]+)"`).
+		FindAllStringSubmatch(page, -1) {
+
+		ids[m[1]] = true
+	}
+	for _, m := range regexp.MustCompile(`href="#([^"]+)"`).
+		FindAllStringSubmatch(page, -1) {
+
+		if !ids[m[1]] {
+			t.Fatal("html links to missing", m[1])
+		}
+	}
+	// a subtree does not link to its parent, which is not on the page
+	if sub := string(ex.GetCommand("pod123 wallet").HTML()); strings.Contains(
+		sub, "href=\"#pod123\"") {
+
+		t.Fatal("subtree links to its parent")
+	}
+}
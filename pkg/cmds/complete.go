@@ -0,0 +1,106 @@
+package cmds
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/util"
+)
+
+// Complete returns the possible completions of the last of the words of a
+// command line, not including the program name. The Command must be the root.
+//
+// Subcommand names, except Hidden ones, are completed, and after a '-' the
+// names of the options of the current subcommand. The value of an option is
+// completed from its choices, either after '=' or as the word following the
+// option.
+func (c *Command) Complete(words []string) (candidates []string) {
+	if len(words) < 1 {
+		words = []string{""}
+	}
+	cmd := c
+	for _, w := range words[:len(words)-1] {
+		for i := range cmd.Commands {
+			if util.Norm(cmd.Commands[i].Name) == util.Norm(w) {
+				cmd = cmd.Commands[i]
+				break
+			}
+		}
+	}
+	cur := words[len(words)-1]
+	withPrefix := func(prefix string, options []string) {
+		for _, o := range options {
+			if strings.HasPrefix(util.Norm(o), util.Norm(prefix)) {
+				candidates = append(candidates, o)
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(cur, "-"):
+		name := strings.TrimLeft(cur, "-")
+		dashes := cur[:len(cur)-len(name)]
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			if op := cmd.findConfig(name[:i]); op != nil {
+				for _, v := range completionValues(op) {
+					if strings.HasPrefix(util.Norm(v),
+						util.Norm(name[i+1:])) {
+
+						candidates = append(candidates,
+							dashes+name[:i+1]+v)
+					}
+				}
+			}
+			break
+		}
+		var names []string
+		for n := range cmd.Configs {
+			names = append(names, dashes+strings.ToLower(n))
+		}
+		withPrefix(cur, names)
+	default:
+		if len(words) > 1 {
+			prev := words[len(words)-2]
+			if strings.HasPrefix(prev, "-") && !strings.Contains(prev, "=") {
+				op := cmd.findConfig(strings.TrimLeft(prev, "-"))
+				if op != nil && op.Type() != meta.Bool {
+					withPrefix(cur, completionValues(op))
+					break
+				}
+			}
+		}
+		var names []string
+		for i := range cmd.Commands {
+			if !cmd.Commands[i].Hidden {
+				names = append(names, cmd.Commands[i].Name)
+			}
+		}
+		withPrefix(cur, names)
+	}
+	sort.Strings(candidates)
+	return
+}
+
+// findConfig finds an option of the Command by name or alias.
+func (c *Command) findConfig(name string) config.Option {
+	for n := range c.Configs {
+		if util.Norm(n) == util.Norm(name) {
+			return c.Configs[n]
+		}
+		for _, a := range c.Configs[n].Meta().Aliases() {
+			if util.Norm(a) == util.Norm(name) {
+				return c.Configs[n]
+			}
+		}
+	}
+	return nil
+}
+
+// completionValues returns the values an option can be completed with.
+func completionValues(op config.Option) []string {
+	if op.Type() == meta.Bool {
+		return []string{"false", "true"}
+	}
+	return op.Meta().Options()
+}
@@ -0,0 +1,861 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/crypt"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/secret"
+	path2 "github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
+	"github.com/naoina/toml"
+	"github.com/naoina/toml/ast"
+	"golang.org/x/term"
+)
+
+// Config is a default top level command with subcommands for managing the
+// configuration file.
+func Config() (c *Command) {
+	c = &Command{
+		Name:        "config",
+		Description: "Manage the configuration file",
+		Documentation: strings.TrimSpace(`
+Subcommands to inspect and change the configuration file.
+
+An encrypted configuration file is opened and saved with the passphrase from
+ConfigPassphrase, its environment variable, the file named by ConfigKeyFile, or
+asked for on the terminal, in that order. The decrypted configuration is only
+ever held in memory.
+`),
+		Entrypoint: func(c *Command, args []string) (err error) {
+			return HelpEntrypoint(c, []string{"config"})
+		},
+		Commands: Commands{
+			{
+				Name:        "path",
+				Description: "print the location of the configuration file",
+				Documentation: strings.TrimSpace(`
+Prints the resolved path of the configuration file.
+`),
+				Entrypoint: func(c *Command, args []string) (err error) {
+					fmt.Println(c.configFile())
+					return
+				},
+			},
+			{
+				Name:        "get",
+				Description: "print the value of an option",
+				Documentation: strings.TrimSpace(`
+Prints the current value of the option at the path, such as 'node MaxPeers'.
+The name of the application at the start of the path can be left out. Secrets
+are printed redacted.
+`),
+				Args:       Tags("<path>"),
+				Entrypoint: configGet,
+			},
+			{
+				Name:        "set",
+				Description: "change the value of an option and save it",
+				Documentation: strings.TrimSpace(`
+Sets the option at the path to the value, which is checked the same way as a
+value given on the command line, and writes it into the configuration file.
+Only the line of the option changes, so values from the environment, the
+command line and profiles are not saved with it.
+`),
+				Args:       Tags("<path>", "<value>"),
+				Entrypoint: configSet,
+			},
+			{
+				Name:        "unset",
+				Description: "return an option to its default and save it",
+				Documentation: strings.TrimSpace(`
+Sets the option at the path back to its default value and removes it from the
+configuration file.
+`),
+				Args:       Tags("<path>"),
+				Entrypoint: configUnset,
+			},
+			{
+				Name:        "list",
+				Description: "print the values of all options",
+				Documentation: strings.TrimSpace(`
+Prints the path and value of every option. With --tag=<expression>, which can
+be given more than once, only options whose tags match one of the expressions
+are shown. An expression is a tag, or tags combined with &&, || and !, such as
+'node && !tls', which needs quotes in a shell. With
+--profile=<name>, which can also be given more than once, the values are shown
+as they are with the profiles applied in order on top of the configuration
+file. Other arguments are a path that the options must be under.
+`),
+				Args: Tags("[--tag=<expression>]...", "[--profile=<name>]...",
+					"[path]"),
+				Entrypoint: configList,
+			},
+			{
+				Name:        "tags",
+				Description: "print the tags of the options",
+				Documentation: strings.TrimSpace(`
+Prints each tag used by the options with the number of options that have it.
+Tags select options in 'config list', 'config export' and 'help'.
+`),
+				Entrypoint: func(c *Command, args []string) (err error) {
+					for _, t := range c.TagCounts() {
+						fmt.Printf("%s %d\n", t.Tag, t.Count)
+					}
+					return
+				},
+			},
+			{
+				Name:        "export",
+				Description: "print a configuration file of some options",
+				Documentation: strings.TrimSpace(`
+Prints a configuration file with the options whose tags match the expression
+given with --tag, which can be given more than once, as in 'config list'. This
+makes configuration files for one role of the application, such as with
+--tag=wallet. With --env the options are printed as a dotenv file of
+environment variables instead.
+`),
+				Args:       Tags("[--tag=<expression>]...", "[--env]"),
+				Entrypoint: configExport,
+			},
+			{
+				Name:        "diff",
+				Description: "print the differences between configurations",
+				Documentation: strings.TrimSpace(`
+Prints the options whose values differ between two configuration files, or with
+one file, between the defaults and the file. Each file is read as it would be
+on startup, so options it does not set have their defaults, and its profiles
+are applied if the Profile option selects them. Changed values are printed as
+'~ path: old -> new', and secrets are redacted.
+`),
+				Args:       Tags("<file>", "[file]"),
+				Entrypoint: configDiff,
+			},
+			{
+				Name:        "edit",
+				Description: "edit the configuration file with $EDITOR",
+				Documentation: strings.TrimSpace(`
+Opens a copy of the configuration file in the editor named by the EDITOR
+environment variable. When the editor exits the copy is checked, and only
+replaces the configuration file if no problems are found.
+
+The copy of an encrypted configuration file is made in memory backed storage
+and encrypted again when it is saved.
+`),
+				Entrypoint: configEdit,
+			},
+			{
+				Name:        "validate",
+				Description: "check the configuration file for problems",
+				Documentation: strings.TrimSpace(`
+Parses the configuration file and prints every problem found with its file
+name, line and column: syntax errors, duplicate and unknown keys, values of the
+wrong type and values that are not one of the valid choices.
+`),
+				Entrypoint: func(c *Command, args []string) (err error) {
+					var diags Diagnostics
+					if diags, err = c.ValidateConfig(); err != nil &&
+						len(diags) == 0 {
+
+						return
+					}
+					for i := range diags {
+						fmt.Println(diags[i])
+					}
+					if len(diags) > 0 {
+						return fmt.Errorf("%d problems found in %s",
+							len(diags), c.configFile())
+					}
+					fmt.Println(c.configFile(), "is valid")
+					return nil
+				},
+			},
+			{
+				Name:        "schema",
+				Description: "print the JSON Schema of the configuration file",
+				Documentation: strings.TrimSpace(`
+Prints a JSON Schema describing every option of the configuration file with its
+type, default and valid choices, for editors that check and complete files.
+`),
+				Entrypoint: func(c *Command, args []string) (err error) {
+					var schema []byte
+					if schema, err = c.JSONSchema(); err != nil {
+						return
+					}
+					fmt.Println(string(schema))
+					return
+				},
+			},
+			{
+				Name:        "encrypt",
+				Description: "encrypt the configuration file",
+				Documentation: strings.TrimSpace(`
+Encrypts the configuration file in place with a passphrase, which is asked for
+twice if it is entered on the terminal.
+`),
+				Entrypoint: func(c *Command, args []string) (err error) {
+					var data []byte
+					if data, err = os.ReadFile(c.configFile()); log.E.Chk(err) {
+						return
+					}
+					if crypt.IsEncrypted(data) {
+						return fmt.Errorf("configuration file %s is already "+
+							"encrypted", c.configFile())
+					}
+					var pass []byte
+					if pass, err = c.configPassphrase(true); log.E.Chk(err) {
+						return
+					}
+					if data, err = crypt.Encrypt(data, pass); log.E.Chk(err) {
+						return
+					}
+					return writeConfigFile(c.configFile(), data)
+				},
+			},
+			{
+				Name:        "decrypt",
+				Description: "decrypt the configuration file",
+				Documentation: strings.TrimSpace(`
+Replaces an encrypted configuration file with its plaintext.
+`),
+				Entrypoint: func(c *Command, args []string) (err error) {
+					var data []byte
+					if data, err = c.readConfig(); log.E.Chk(err) {
+						return
+					}
+					return writeConfigFile(c.configFile(), data)
+				},
+			},
+			{
+				Name:        "rekey",
+				Description: "change the passphrase of the configuration file",
+				Documentation: strings.TrimSpace(`
+Encrypts the configuration file with a new passphrase. The new passphrase is
+read from the file given as the argument, or asked for twice on the terminal.
+`),
+				Args: Tags("[new key file]"),
+				Entrypoint: func(c *Command, args []string) (err error) {
+					var data []byte
+					if data, err = c.readConfig(); log.E.Chk(err) {
+						return
+					}
+					var pass []byte
+					if len(args) > 0 {
+						if pass, err = readKeyFile(args[0]); log.E.Chk(err) {
+							return
+						}
+					} else if pass, err = promptPassphrase(
+						"new passphrase", true); log.E.Chk(err) {
+
+						return
+					}
+					if data, err = crypt.Encrypt(data, pass); log.E.Chk(err) {
+						return
+					}
+					if err = writeConfigFile(c.configFile(), data); log.E.Chk(err) {
+						return
+					}
+					c.GetOpt(path2.Path{c.Name, "ConfigPassphrase"}).(*secret.Opt).
+						FromValue(string(pass))
+					return
+				},
+			},
+		},
+	}
+	return
+}
+
+// configFile returns the resolved path of the configuration file.
+func (c *Command) configFile() string {
+	return c.GetOpt(path2.Path{c.Name, "ConfigFile"}).Expanded()
+}
+
+// readConfig returns the content of the configuration file, decrypting it if
+// it is encrypted.
+func (c *Command) readConfig() (data []byte, err error) {
+	return c.readConfigFile(c.configFile())
+}
+
+// readConfigFile returns the content of a configuration file, decrypting it
+// with the passphrase of the configuration if it is encrypted.
+func (c *Command) readConfigFile(file string) (data []byte, err error) {
+	if data, err = os.ReadFile(file); err != nil {
+		return
+	}
+	if crypt.IsEncrypted(data) {
+		var pass []byte
+		if pass, err = c.configPassphrase(false); log.E.Chk(err) {
+			return
+		}
+		if data, err = crypt.Decrypt(data, pass); err != nil {
+			err = fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return
+}
+
+// writeConfig writes the configuration file, encrypting it if the file it
+// replaces is encrypted.
+func (c *Command) writeConfig(data []byte) (err error) {
+	var current []byte
+	if current, err = os.ReadFile(c.configFile()); err == nil &&
+		crypt.IsEncrypted(current) {
+
+		var pass []byte
+		if pass, err = c.configPassphrase(false); log.E.Chk(err) {
+			return
+		}
+		if data, err = crypt.Encrypt(data, pass); log.E.Chk(err) {
+			return
+		}
+	}
+	return writeConfigFile(c.configFile(), data)
+}
+
+// writeConfigFile replaces the file at path with data. The data is written to
+// a temporary file in the same directory, which is synced and renamed over
+// the file, so the file is never left partly written. An encrypted file, or
+// a new one, is only readable by the owner, otherwise the file keeps its
+// mode.
+func writeConfigFile(path string, data []byte) (err error) {
+	var perm os.FileMode = 0600
+	if fi, e := os.Stat(path); e == nil && !crypt.IsEncrypted(data) {
+		perm = fi.Mode().Perm()
+	}
+	var f *os.File
+	if f, err = os.CreateTemp(filepath.Dir(path),
+		"."+filepath.Base(path)+".*"); log.E.Chk(err) {
+
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err = f.Chmod(perm); log.E.Chk(err) {
+		return
+	}
+	if _, err = f.Write(data); log.E.Chk(err) {
+		return
+	}
+	if err = f.Sync(); log.E.Chk(err) {
+		return
+	}
+	if err = f.Close(); log.E.Chk(err) {
+		return
+	}
+	err = os.Rename(f.Name(), path)
+	log.E.Chk(err)
+	return
+}
+
+// configPassphrase finds the passphrase for the configuration file and keeps
+// it in the ConfigPassphrase option so it is only asked for once.
+func (c *Command) configPassphrase(confirm bool) (pass []byte, err error) {
+	op := c.GetOpt(path2.Path{c.Name, "ConfigPassphrase"}).(*secret.Opt)
+	if op.Secret() != "" {
+		return []byte(op.Secret()), nil
+	}
+	// the environment is not loaded until after the configuration
+	p := path2.Path{c.Name, "ConfigPassphrase"}
+	env := Env{Name: p, Var: c.EnvVar(p), Aliases: op.Meta().EnvAliases()}
+	for _, name := range env.Vars() {
+		if v, src, exists := c.lookupEnv(name); exists {
+			if err = op.FromString(v); err != nil {
+				return nil, fmt.Errorf("from environment variable %s "+
+					"(%s): %w", name, src, err)
+			}
+			return []byte(op.Secret()), nil
+		}
+	}
+	if kf := c.GetOpt(path2.Path{c.Name, "ConfigKeyFile"}); kf.String() != "" {
+		pass, err = readKeyFile(kf.Expanded())
+	} else {
+		pass, err = promptPassphrase("passphrase for "+c.configFile(),
+			confirm)
+	}
+	if err == nil {
+		op.FromValue(string(pass))
+	}
+	return
+}
+
+// readKeyFile reads a passphrase from a file, without trailing line breaks.
+func readKeyFile(path string) (pass []byte, err error) {
+	if pass, err = os.ReadFile(path); err != nil {
+		return
+	}
+	pass = bytes.TrimRight(pass, "\r\n")
+	if len(pass) < 1 {
+		err = fmt.Errorf("key file %s is empty", path)
+	}
+	return
+}
+
+// promptPassphrase asks for a passphrase on the terminal without echoing it,
+// and asks again to confirm it if requested.
+func promptPassphrase(prompt string, confirm bool) (pass []byte, err error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no passphrase given and no terminal to " +
+			"ask for one")
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	pass, err = term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return
+	}
+	if len(pass) < 1 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		fmt.Fprintf(os.Stderr, "repeat %s: ", prompt)
+		var again []byte
+		again, err = term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(pass, again) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return
+}
+
+// optPath turns arguments into the path of an option, adding the name of the
+// root Command if it was left out.
+func (c *Command) optPath(args []string) (p path2.Path) {
+	if len(args) > 0 && util.Norm(args[0]) == util.Norm(c.Name) {
+		return path2.Path(args)
+	}
+	return append(path2.Path{c.Name}, args...)
+}
+
+// findOpt returns the option at the path given by the arguments.
+func (c *Command) findOpt(args []string) (p path2.Path, op config.Option,
+	err error) {
+
+	if len(args) < 1 {
+		return nil, nil, fmt.Errorf("no option path given")
+	}
+	p = c.optPath(args)
+	if op = c.GetOpt(p); op == nil {
+		err = fmt.Errorf("option not found: %s", p)
+	}
+	return
+}
+
+func configGet(c *Command, args []string) (err error) {
+	var op config.Option
+	if _, op, err = c.findOpt(args); err != nil {
+		return
+	}
+	fmt.Println(op.String())
+	return
+}
+
+func configSet(c *Command, args []string) (err error) {
+	if len(args) < 2 {
+		return fmt.Errorf("set needs an option path and a value")
+	}
+	var p path2.Path
+	var op config.Option
+	if p, op, err = c.findOpt(args[:len(args)-1]); err != nil {
+		return
+	}
+	if err = op.FromString(args[len(args)-1]); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	cmd, name := c.GetCommand(op.Path().String()), c.optName(op)
+	v, ok, err := c.tomlSaved(cmd, name, storedValue(op))
+	switch {
+	case err != nil:
+		return fmt.Errorf("%s: %w", p, err)
+	case !ok:
+		return fmt.Errorf("%s is not saved in the configuration file", p)
+	}
+	return c.editConfig(cmd, name, &v)
+}
+
+func configUnset(c *Command, args []string) (err error) {
+	var p path2.Path
+	var op config.Option
+	if p, op, err = c.findOpt(args); err != nil {
+		return
+	}
+	if err = reset(op); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return c.editConfig(c.GetCommand(op.Path().String()), c.optName(op), nil)
+}
+
+// editConfig sets the value of one option of a Command in the configuration
+// file, already formatted as TOML, or removes it if the value is nil. Only the
+// lines of the option change, so values from the environment, the command
+// line or a profile are not written into the file, and its comments and
+// layout are kept. A missing file is created.
+func (c *Command) editConfig(cmd *Command, name string, value *string) (
+	err error) {
+
+	var data []byte
+	if data, err = c.readConfig(); err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		if err = os.MkdirAll(filepath.Dir(c.configFile()), 0700); log.E.Chk(err) {
+			return
+		}
+		data = c.schemaHeader()
+	}
+	var tbl *ast.Table
+	if tbl, err = toml.Parse(data); err != nil {
+		return fmt.Errorf("%s: %w", c.configFile(), err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines[len(lines)-1]) < 1 {
+		lines = lines[:len(lines)-1]
+	}
+	// the lines of the option, if it is in the file, and where it goes if not
+	first, last, at := 0, 0, 0
+	table := tomlTable(tbl, cmd.Path)
+	if table != nil && !isHeader(lines, table.Line, cmd.Path) {
+		table = nil
+	}
+	if table != nil {
+		at = table.Line
+		for k, v := range table.Fields {
+			l, end := fieldLines(data, lines, v)
+			if l < 1 {
+				continue
+			}
+			if util.Norm(k) == util.Norm(name) {
+				first, last, name = l, end, k
+			}
+			if end > at {
+				at = end
+			}
+		}
+	}
+	var edit []string
+	switch {
+	case first > 0 && value == nil:
+		// remove the comment written above the option with it
+		if first > 1 && strings.HasPrefix(lines[first-2], "# "+name+" - ") {
+			first--
+		}
+		edit = append(edit, lines[:first-1]...)
+		edit = append(edit, lines[last:]...)
+	case first > 0:
+		indent := lines[first-1][:len(lines[first-1])-
+			len(strings.TrimLeft(lines[first-1], " \t"))]
+		edit = append(edit, lines[:first-1]...)
+		edit = append(edit, indent+name+" = "+*value+"\n")
+		edit = append(edit, lines[last:]...)
+	case value == nil:
+		return
+	default:
+		md := cmd.Configs[name].Meta()
+		var def string
+		if def, err = tomlValue(cmd.Configs[name], md.Default()); err != nil {
+			return
+		}
+		add := []string{"# " + name + " - " + md.Description() + " - default: " +
+			def + "\n", name + " = " + *value + "\n"}
+		if table == nil {
+			if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+				lines[n-1] += "\n"
+			}
+			add = append([]string{"\n", "[" + strings.Join(cmd.Path, ".") +
+				"]\n"}, add...)
+			at = len(lines)
+		}
+		edit = append(edit, lines[:at]...)
+		edit = append(edit, add...)
+		edit = append(edit, lines[at:]...)
+	}
+	return c.writeConfig([]byte(strings.Join(edit, "")))
+}
+
+// tomlTable returns the table at a path in a parsed file, matching the names
+// like options are matched, or nil if there is none.
+func tomlTable(t *ast.Table, p path2.Path) *ast.Table {
+	for i := range p {
+		var next *ast.Table
+		for k, v := range t.Fields {
+			if sub, ok := v.(*ast.Table); ok && util.Norm(k) == util.Norm(p[i]) {
+				next = sub
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		t = next
+	}
+	return t
+}
+
+// isHeader returns true if a line of a file is the header of the table at a
+// path, which is not so for a table that is only named in the headers of its
+// subtables.
+func isHeader(lines []string, line int, p path2.Path) bool {
+	if line < 1 || line > len(lines) {
+		return false
+	}
+	h := strings.TrimSpace(lines[line-1])
+	if !strings.HasPrefix(h, "[") || !strings.HasSuffix(h, "]") {
+		return false
+	}
+	names := strings.Split(strings.Trim(h, "[]"), ".")
+	if len(names) != len(p) {
+		return false
+	}
+	for i := range names {
+		if util.Norm(strings.TrimSpace(names[i])) != util.Norm(p[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldLines returns the first and last line of a key and its value in a
+// parsed file, or nothing for a subtable with its own header. An inline table
+// is always on one line.
+func fieldLines(data []byte, lines []string, v interface{}) (first,
+	last int) {
+
+	switch f := v.(type) {
+	case *ast.KeyValue:
+		// the line of a key is where its value ends
+		value := []rune(string(data))[f.Value.Pos():f.Value.End()]
+		return f.Line - strings.Count(string(value), "\n"), f.Line
+	case *ast.Table:
+		if f.Line > 0 && f.Line <= len(lines) &&
+			!strings.HasPrefix(strings.TrimSpace(lines[f.Line-1]), "[") {
+
+			return f.Line, f.Line
+		}
+	}
+	return
+}
+
+// reset returns an option to its default value.
+func reset(op config.Option) error {
+	if r, ok := op.(interface{ Reset() error }); ok {
+		return r.Reset()
+	}
+	return op.FromString(op.Meta().Default())
+}
+
+func configList(c *Command, args []string) (err error) {
+	var tags, profiles, prefix []string
+	if err = listFlags(args, map[string]*[]string{
+		"tag": &tags, "profile": &profiles}, nil, &prefix); err != nil {
+
+		return
+	}
+	var q TagQuery
+	if q, err = tagQueries(tags); err != nil {
+		return
+	}
+	var p path2.Path
+	if len(prefix) > 0 {
+		p = c.optPath(prefix)
+	}
+	if len(profiles) > 0 {
+		if err = c.loadProfiles(profiles); err != nil {
+			return
+		}
+	}
+	var lines []string
+	for _, t := range c.selectTags(q) {
+		if hasPrefix(t.Path, p) {
+			lines = append(lines, fmt.Sprintf("%s = %s", t.Path, t.String()))
+		}
+	}
+	sort.Strings(lines)
+	for i := range lines {
+		fmt.Println(lines[i])
+	}
+	return
+}
+
+func configExport(c *Command, args []string) (err error) {
+	var tags, rest []string
+	var env bool
+	if err = listFlags(args, map[string]*[]string{"tag": &tags},
+		map[string]*bool{"env": &env}, &rest); err != nil {
+
+		return
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unknown argument %s", rest[0])
+	}
+	expr := ""
+	if len(tags) > 0 {
+		expr = "(" + strings.Join(tags, ") || (") + ")"
+	}
+	var text []byte
+	if env {
+		text, err = c.ExportEnv(expr)
+	} else {
+		text, err = c.MarshalTags(expr)
+	}
+	if err == nil {
+		fmt.Print(string(text))
+	}
+	return
+}
+
+// listFlags reads the flags of a subcommand into the lists of their values,
+// for flags such as --tag=node that can be given more than once, or for
+// switches such as --env, into their bools. Other arguments are added to
+// rest. A flag must be followed by its value if it has no '='.
+func listFlags(args []string, flags map[string]*[]string,
+	switches map[string]*bool, rest *[]string) (err error) {
+
+	for i := 0; i < len(args); i++ {
+		a := strings.TrimLeft(args[i], "-")
+		if a == args[i] {
+			*rest = append(*rest, args[i])
+			continue
+		}
+		name, value, hasValue := a, "", false
+		if j := strings.IndexByte(a, '='); j >= 0 {
+			name, value, hasValue = a[:j], a[j+1:], true
+		}
+		if sw, ok := switches[util.Norm(name)]; ok && !hasValue {
+			*sw = true
+			continue
+		}
+		to, ok := flags[util.Norm(name)]
+		if !ok {
+			return fmt.Errorf("unknown flag %s", args[i])
+		}
+		if !hasValue {
+			if i++; i >= len(args) {
+				return fmt.Errorf("--%s needs a value", util.Norm(name))
+			}
+			value = args[i]
+		}
+		*to = append(*to, value)
+	}
+	return
+}
+
+// loadProfiles reads the configuration file again with the given profiles
+// applied on top of it.
+func (c *Command) loadProfiles(names []string) (err error) {
+	var all []byte
+	if all, err = c.readConfig(); err != nil {
+		return
+	}
+	var selected []string
+	for i := range names {
+		selected = append(selected, strings.Split(names[i], ",")...)
+	}
+	c.GetOpt(path2.Path{c.Name, "Profile"}).(*list.Opt).FromValue(selected)
+	_, _, err = c.decode(c.configFile(), all, false)
+	return
+}
+
+// hasPrefix returns true if the path starts with the prefix.
+func hasPrefix(p, prefix path2.Path) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	return p[:len(prefix)].Equal(prefix)
+}
+
+func configEdit(c *Command, args []string) (err error) {
+	var current []byte
+	if current, err = os.ReadFile(c.configFile()); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = c.readConfig(); err != nil {
+		return
+	}
+	dir := ""
+	if crypt.IsEncrypted(current) {
+		// the plaintext must not be written to disk
+		if dir = memoryTempDir(); dir == "" {
+			return fmt.Errorf("no memory backed directory to edit an " +
+				"encrypted configuration in, use decrypt and encrypt")
+		}
+	}
+	var f *os.File
+	if f, err = os.CreateTemp(dir, "config-*.toml"); err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	if err = f.Chmod(0600); err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) < 1 {
+		editor = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("running editor %s: %w", editor[0], err)
+	}
+	var edited []byte
+	if edited, err = os.ReadFile(f.Name()); err != nil {
+		return
+	}
+	if bytes.Equal(edited, data) {
+		fmt.Println("no changes made")
+		return
+	}
+	var diags Diagnostics
+	if _, diags, err = c.decode(c.configFile(), edited,
+		true); len(diags) > 0 {
+
+		for i := range diags {
+			fmt.Println(diags[i])
+		}
+		return fmt.Errorf("%d problems found, %s was not changed",
+			len(diags), c.configFile())
+	}
+	if err != nil {
+		return
+	}
+	return c.writeConfig(edited)
+}
+
+// memoryTempDir returns a directory for temporary files that is not stored on
+// disk, or an empty string if there is none.
+func memoryTempDir() string {
+	for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if dir == "" {
+			continue
+		}
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
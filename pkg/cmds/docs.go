@@ -0,0 +1,418 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts/secret"
+)
+
+// DocPage is a page of reference documentation, with the file name it is
+// written as, such as pod123-node.md.
+type DocPage struct {
+	Name string
+	Text []byte
+}
+
+// docOption is an option of a Command as it is documented.
+type docOption struct {
+	name, typ, def, key, anchor, description, documentation string
+	flags, env, tags                                        []string
+}
+
+// docOptions returns the options of the Command in a section for reference
+// documentation. The Command must be initialised with Init.
+func (c *Command) docOptions(sec Section) (opts []docOption) {
+	r := c.root()
+	for _, name := range sec.Names {
+		op := c.Configs[name]
+		om := op.Meta()
+		o := docOption{
+			name:          name,
+			typ:           string(op.Type()),
+			def:           om.Default(),
+			anchor:        manName(c.Path) + "--" + strings.ToLower(name),
+			description:   om.Description(),
+			documentation: om.Documentation(),
+			env: append([]string{r.EnvVar(c.Path.Child(name))},
+				om.EnvAliases()...),
+			tags: om.Tags(),
+		}
+		// secrets that are not saved have no key in the configuration file
+		if s, ok := op.(*secret.Opt); !ok ||
+			s.SaveMode() != secret.SaveOmit {
+
+			o.key = strings.Join(c.Path.Child(name), ".")
+		}
+		for _, f := range append([]string{name}, om.Aliases()...) {
+			o.flags = append(o.flags, "-"+strings.ToLower(f))
+		}
+		opts = append(opts, o)
+	}
+	return
+}
+
+// usage returns the command line of the Command for its documentation.
+func (c *Command) usage() string {
+	u := []string{strings.Join(c.Path, " ")}
+	if len(c.Configs) > 0 {
+		u = append(u, "[options]")
+	}
+	if len(c.CommandSections()) > 0 {
+		u = append(u, "[subcommand]")
+	}
+	return strings.Join(append(u, c.Args...), " ")
+}
+
+// ExampleConfig returns the section of a configuration file with the options
+// of the Command set to their defaults, or nothing if it has no options that
+// are saved.
+func (c *Command) ExampleConfig() (text []byte) {
+	r := c.root()
+	var lines []string
+	for _, sec := range c.OptionSections() {
+		for _, name := range sec.Names {
+			md := c.Configs[name].Meta()
+			v, ok, err := r.tomlSaved(c, name, md.Default())
+			switch {
+			case log.E.Chk(err) || !ok:
+				continue
+			case v == "":
+				// numbers and toggles with no default have no value to show
+				lines = append(lines, "# "+name+" - "+md.Description(),
+					"# "+name+" = ")
+				continue
+			}
+			lines = append(lines, "# "+name+" - "+md.Description(),
+				name+" = "+v)
+		}
+	}
+	if len(lines) < 1 {
+		return
+	}
+	return []byte(fmt.Sprintf("[%s]\n%s\n", strings.Join(c.Path, "."),
+		strings.Join(lines, "\n")))
+}
+
+// docCommands returns the subcommands of the Command that are documented,
+// which are those that are not Hidden, with their subcommands, parents first.
+func (c *Command) docCommands() (all Commands) {
+	all = append(all, c)
+	for _, cm := range c.Commands {
+		if !cm.Hidden {
+			all = append(all, cm.docCommands()...)
+		}
+	}
+	return
+}
+
+// MarkdownPages returns a Markdown page for the Command and for each of its
+// subcommands, except Hidden ones, linked to their parent and subcommands.
+// The Command must be initialised with Init.
+func (c *Command) MarkdownPages() (pages []DocPage) {
+	for _, cm := range c.docCommands() {
+		pages = append(pages, DocPage{manName(cm.Path) + ".md",
+			cm.Markdown()})
+	}
+	return
+}
+
+// WriteMarkdown writes the pages of MarkdownPages into a directory, which is
+// created if it does not exist, returning the paths of the files.
+func (c *Command) WriteMarkdown(dir string) (files []string, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	for _, p := range c.MarkdownPages() {
+		f := filepath.Join(dir, p.Name)
+		if err = os.WriteFile(f, p.Text, 0644); err != nil {
+			return
+		}
+		files = append(files, f)
+	}
+	return
+}
+
+// Markdown returns the Markdown page of the Command, with its documentation,
+// subcommands, a table of its options and an example configuration. Links to
+// other commands are to the files of MarkdownPages.
+func (c *Command) Markdown() []byte {
+	var b bytes.Buffer
+	link := func(cm *Command) string {
+		return fmt.Sprintf("[%s](%s.md)", mdText(strings.Join(cm.Path, " ")),
+			manName(cm.Path))
+	}
+	fmt.Fprintf(&b, "# %s\n\n%s\n\n", mdText(strings.Join(c.Path, " ")),
+		mdText(c.Description))
+	if c.Parent != nil {
+		fmt.Fprintf(&b, "Part of %s.\n\n", link(c.Parent))
+	}
+	fmt.Fprintf(&b, "## Usage\n\n```\n%s\n```\n\n", c.usage())
+	if c.Documentation != "" {
+		b.WriteString("## Documentation\n\n")
+		for _, p := range paragraphs(c.Documentation) {
+			b.WriteString(p.markdown() + "\n")
+		}
+	}
+	if sections := c.CommandSections(); len(sections) > 0 {
+		b.WriteString("## Commands\n\n")
+		for _, sec := range sections {
+			if sec.Name != "" {
+				fmt.Fprintf(&b, "### %s\n\n", mdText(sec.Name))
+			}
+			b.WriteString("| Command | Description |\n| --- | --- |\n")
+			for _, n := range sec.Names {
+				cm := c.subcommand(n)
+				fmt.Fprintf(&b, "| %s | %s |\n", link(cm),
+					mdCell(mdText(cm.Description)))
+			}
+			b.WriteString("\n")
+		}
+		if len(c.Default) > 0 {
+			fmt.Fprintf(&b, "Without a subcommand, %s is run.\n\n",
+				mdCode(c.Default[len(c.Default)-1]))
+		}
+	}
+	if len(c.Configs) > 0 {
+		b.WriteString("## Options\n\n")
+		for _, sec := range c.OptionSections() {
+			if sec.Name != "" {
+				fmt.Fprintf(&b, "### %s\n\n", mdText(sec.Name))
+			}
+			opts := c.docOptions(sec)
+			b.WriteString("| Name | Aliases | Type | Default | Environment " +
+				"| Configuration key | Tags |\n" +
+				"| --- | --- | --- | --- | --- | --- | --- |\n")
+			for _, o := range opts {
+				fmt.Fprintf(&b, "| [%s](#%s) | %s | %s | %s | %s | %s "+
+					"| %s |\n", mdCode(o.flags[0]), o.anchor, mdCodes(o.flags[1:]),
+					o.typ, mdCode(o.def), mdCodes(o.env), mdCode(o.key),
+					mdCell(mdText(strings.Join(o.tags, ", "))))
+			}
+			b.WriteString("\n")
+			for _, o := range opts {
+				fmt.Fprintf(&b, "<a id=\"%s\"></a>\n#### %s\n\n%s\n\n",
+					o.anchor, mdText(o.name), mdText(o.description))
+				if o.documentation != "" &&
+					o.documentation != o.description {
+
+					for _, p := range paragraphs(o.documentation) {
+						b.WriteString(p.markdown() + "\n")
+					}
+				}
+			}
+		}
+	}
+	if ex := c.ExampleConfig(); len(ex) > 0 {
+		fmt.Fprintf(&b, "## Example configuration\n\n```toml\n%s```\n",
+			ex)
+	}
+	return append(bytes.TrimRight(b.Bytes(), "\n"), '\n')
+}
+
+// HTML returns a single HTML page documenting the Command and all of its
+// subcommands, except Hidden ones, as Markdown does, with a contents list and
+// links between the sections of the commands and their options. The Command
+// must be initialised with Init.
+func (c *Command) HTML() []byte {
+	var b bytes.Buffer
+	esc := html.EscapeString
+	link := func(cm *Command) string {
+		return fmt.Sprintf("<a href=\"#%s\">%s</a>", manName(cm.Path),
+			esc(strings.Join(cm.Path, " ")))
+	}
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n"+
+		"<meta charset=\"utf-8\">\n<title>%s reference</title>\n"+
+		"<style>\ntable { border-collapse: collapse; }\n"+
+		"th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; "+
+		"text-align: left; vertical-align: top; }\n</style>\n"+
+		"</head>\n<body>\n", esc(strings.Join(c.Path, " ")))
+	all := c.docCommands()
+	b.WriteString("<nav>\n<ul>\n")
+	for _, cm := range all {
+		fmt.Fprintf(&b, "<li style=\"margin-left: %dem\">%s - %s</li>\n",
+			2*(len(cm.Path)-len(c.Path)), link(cm), esc(cm.Description))
+	}
+	b.WriteString("</ul>\n</nav>\n")
+	for _, cm := range all {
+		fmt.Fprintf(&b, "<section id=\"%s\">\n<h1>%s</h1>\n<p>%s</p>\n",
+			manName(cm.Path), esc(strings.Join(cm.Path, " ")),
+			esc(cm.Description))
+		if cm != c {
+			fmt.Fprintf(&b, "<p>Part of %s.</p>\n", link(cm.Parent))
+		}
+		fmt.Fprintf(&b, "<h2>Usage</h2>\n<pre>%s</pre>\n", esc(cm.usage()))
+		if cm.Documentation != "" {
+			b.WriteString("<h2>Documentation</h2>\n")
+			for _, p := range paragraphs(cm.Documentation) {
+				b.WriteString(p.html())
+			}
+		}
+		if sections := cm.CommandSections(); len(sections) > 0 {
+			b.WriteString("<h2>Commands</h2>\n")
+			for _, sec := range sections {
+				if sec.Name != "" {
+					fmt.Fprintf(&b, "<h3>%s</h3>\n", esc(sec.Name))
+				}
+				b.WriteString("<table>\n<tr><th>Command</th>" +
+					"<th>Description</th></tr>\n")
+				for _, n := range sec.Names {
+					sub := cm.subcommand(n)
+					fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td></tr>\n",
+						link(sub), esc(sub.Description))
+				}
+				b.WriteString("</table>\n")
+			}
+			if len(cm.Default) > 0 {
+				fmt.Fprintf(&b, "<p>Without a subcommand, <code>%s</code> "+
+					"is run.</p>\n", esc(cm.Default[len(cm.Default)-1]))
+			}
+		}
+		if len(cm.Configs) > 0 {
+			b.WriteString("<h2>Options</h2>\n")
+			for _, sec := range cm.OptionSections() {
+				if sec.Name != "" {
+					fmt.Fprintf(&b, "<h3>%s</h3>\n", esc(sec.Name))
+				}
+				opts := cm.docOptions(sec)
+				b.WriteString("<table>\n<tr><th>Name</th><th>Aliases</th>" +
+					"<th>Type</th><th>Default</th><th>Environment</th>" +
+					"<th>Configuration key</th><th>Tags</th></tr>\n")
+				for _, o := range opts {
+					fmt.Fprintf(&b, "<tr><td><a href=\"#%s\"><code>%s"+
+						"</code></a></td><td>%s</td><td>%s</td><td>%s</td>"+
+						"<td>%s</td><td>%s</td><td>%s</td></tr>\n",
+						o.anchor, esc(o.flags[0]), htmlCodes(o.flags[1:]),
+						esc(o.typ), htmlCodes([]string{o.def}),
+						htmlCodes(o.env), htmlCodes([]string{o.key}),
+						esc(strings.Join(o.tags, ", ")))
+				}
+				b.WriteString("</table>\n<dl>\n")
+				for _, o := range opts {
+					fmt.Fprintf(&b, "<dt id=\"%s\"><code>%s</code></dt>\n"+
+						"<dd>\n<p>%s</p>\n", o.anchor, esc(o.name),
+						esc(o.description))
+					if o.documentation != "" &&
+						o.documentation != o.description {
+
+						for _, p := range paragraphs(o.documentation) {
+							b.WriteString(p.html())
+						}
+					}
+					b.WriteString("</dd>\n")
+				}
+				b.WriteString("</dl>\n")
+			}
+		}
+		if ex := cm.ExampleConfig(); len(ex) > 0 {
+			fmt.Fprintf(&b, "<h2>Example configuration</h2>\n<pre>%s</pre>\n",
+				esc(string(ex)))
+		}
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes()
+}
+
+// paragraph is a paragraph of documentation. A paragraph with indented lines
+// is preformatted, and keeps their indent less the first tab.
+type paragraph struct {
+	lines []string
+	pre   bool
+}
+
+// paragraphs splits documentation into paragraphs where it has blank lines.
+func paragraphs(doc string) (p []paragraph) {
+	var cur paragraph
+	flush := func() {
+		if len(cur.lines) < 1 {
+			return
+		}
+		for i := range cur.lines {
+			if cur.pre {
+				cur.lines[i] = strings.TrimPrefix(cur.lines[i], "\t")
+			} else {
+				cur.lines[i] = strings.TrimSpace(cur.lines[i])
+			}
+		}
+		p = append(p, cur)
+		cur = paragraph{}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		if line = strings.TrimRight(line, " \t"); line == "" {
+			flush()
+			continue
+		}
+		cur.pre = cur.pre || strings.TrimLeft(line, " \t") != line
+		cur.lines = append(cur.lines, line)
+	}
+	flush()
+	return
+}
+
+func (p paragraph) markdown() string {
+	if p.pre {
+		return "```\n" + strings.Join(p.lines, "\n") + "\n```\n"
+	}
+	return mdText(strings.Join(p.lines, "\n")) + "\n"
+}
+
+func (p paragraph) html() string {
+	if p.pre {
+		return "<pre>" + html.EscapeString(strings.Join(p.lines, "\n")) +
+			"</pre>\n"
+	}
+	return "<p>" + html.EscapeString(strings.Join(p.lines, "\n")) + "</p>\n"
+}
+
+// mdText escapes the characters of text that Markdown would read as
+// formatting.
+func mdText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>#|", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// mdCell makes text fit in a cell of a Markdown table.
+func mdCell(s string) string {
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// mdCode writes text as Markdown code, or nothing if it is empty.
+func mdCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.ReplaceAll(s, "|", `\|`)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+func mdCodes(s []string) string {
+	c := make([]string, len(s))
+	for i := range s {
+		c[i] = mdCode(s[i])
+	}
+	return strings.Join(c, ", ")
+}
+
+func htmlCodes(s []string) string {
+	var c []string
+	for i := range s {
+		if s[i] != "" {
+			c = append(c, "<code>"+html.EscapeString(s[i])+"</code>")
+		}
+	}
+	return strings.Join(c, ", ")
+}
@@ -0,0 +1,336 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/secret"
+	path2 "github.com/cybriq/proc/pkg/path"
+)
+
+// DotEnvFilename is the name of the dotenv files looked for in the working
+// directory and the data directory.
+const DotEnvFilename = ".env"
+
+// DotEnv is a set of variables read from dotenv files, with the file and line
+// each value came from.
+type DotEnv struct {
+	Values  map[string]string
+	Sources map[string]string
+}
+
+// NewDotEnv creates an empty DotEnv.
+func NewDotEnv() *DotEnv {
+	return &DotEnv{
+		Values:  make(map[string]string),
+		Sources: make(map[string]string),
+	}
+}
+
+// Lookup returns an EnvLookup that finds variables in the process environment
+// and the DotEnv. Values in the process environment take precedence unless
+// override is set.
+func (d *DotEnv) Lookup(override bool) EnvLookup {
+	return func(name string) (value, source string, ok bool) {
+		if !override {
+			if value, source, ok = ProcessEnv(name); ok {
+				return
+			}
+		}
+		if value, ok = d.Values[name]; ok {
+			return value, d.Sources[name], true
+		}
+		return ProcessEnv(name)
+	}
+}
+
+// ReadFile parses a dotenv file and adds its variables, replacing any of the
+// same name read before.
+func (d *DotEnv) ReadFile(name string) (err error) {
+	var data []byte
+	if data, err = os.ReadFile(name); err != nil {
+		return
+	}
+	return d.Parse(name, data)
+}
+
+// Parse reads variables in dotenv syntax:
+//
+//   - one NAME=value per line, optionally preceded by 'export'
+//   - blank lines and lines starting with '#' are ignored
+//   - unquoted values end at a ' #' comment and have spaces trimmed
+//   - single quoted values are taken literally
+//   - double quoted values can span lines, and understand the escapes \n, \r,
+//     \t, \", \\ and \$
+//   - ${NAME} in unquoted and double quoted values is replaced with the value
+//     of the variable, from this or earlier files or the process environment
+//
+// A dotenv file in the working directory is read wherever the application is
+// run, so the files are not trusted to run commands: a value that is a cmd:
+// reference is an error, see opts.CommandProvider.
+func (d *DotEnv) Parse(file string, data []byte) (err error) {
+	src := strings.ReplaceAll(string(data), "\r\n", "\n")
+	line := 1
+	for len(src) > 0 {
+		start := line
+		var l string
+		if i := strings.IndexByte(src, '\n'); i >= 0 {
+			l, src = src[:i], src[i+1:]
+		} else {
+			l, src = src, ""
+		}
+		line++
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if strings.HasPrefix(l, "export ") || strings.HasPrefix(l, "export\t") {
+			l = strings.TrimSpace(l[len("export"):])
+		}
+		fail := func(format string, a ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", file, start, fmt.Sprintf(format, a...))
+		}
+		eq := strings.IndexByte(l, '=')
+		if eq < 1 {
+			return fail("expected NAME=value")
+		}
+		name, value := strings.TrimSpace(l[:eq]), strings.TrimLeft(l[eq+1:], " \t")
+		if !validEnvName(name) {
+			return fail("invalid variable name '%s'", name)
+		}
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return fail("unterminated single quote")
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, "\""):
+			// a double quoted value continues on the following lines until
+			// the closing quote
+			value = value[1:]
+			var b strings.Builder
+			for {
+				end := closingQuote(value)
+				if end >= 0 {
+					b.WriteString(value[:end])
+					break
+				}
+				if src == "" {
+					return fail("unterminated double quote")
+				}
+				b.WriteString(value + "\n")
+				if i := strings.IndexByte(src, '\n'); i >= 0 {
+					value, src = src[:i], src[i+1:]
+				} else {
+					value, src = src, ""
+				}
+				line++
+			}
+			if value, err = d.expand(unescape(b.String())); err != nil {
+				return fail("%v", err)
+			}
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			if value, err = d.expand(strings.TrimSpace(value)); err != nil {
+				return fail("%v", err)
+			}
+		}
+		if opts.IsCommand(value) {
+			return fail("%s: %s: references are not allowed in dotenv files",
+				name, opts.CommandScheme)
+		}
+		d.Values[name] = value
+		d.Sources[name] = fmt.Sprintf("%s:%d", file, start)
+	}
+	return
+}
+
+// validEnvName returns true if a name has only letters, digits and
+// underscores and does not start with a digit.
+func validEnvName(name string) bool {
+	return EnvSanitise(name) == strings.ToUpper(name)
+}
+
+// closingQuote finds the first double quote that is not escaped.
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape replaces the escape sequences of double quoted values. An escaped
+// dollar sign is kept as \$ so that expand does not treat it as a variable.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '$':
+			b.WriteString("\\$")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// expand replaces ${NAME} with the value of a variable read before, or from
+// the process environment, and \$ with a dollar sign.
+func (d *DotEnv) expand(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "\\$"):
+			b.WriteByte('$')
+			i++
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference")
+			}
+			name := s[i+2 : i+end]
+			if v, ok := d.Values[name]; ok {
+				b.WriteString(v)
+			} else {
+				b.WriteString(os.Getenv(name))
+			}
+			i += end
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// DotEnvFiles returns the dotenv files to read, in the order they are read,
+// which is the data directory, the working directory and the files given in
+// the EnvFile option. Later files replace the values of earlier ones. It must
+// be called on the root Command.
+func (c *Command) DotEnvFiles() (files []string) {
+	optional, given := c.dotEnvFiles()
+	return append(optional, given...)
+}
+
+// dotEnvFiles returns the files in the data and working directories, which
+// are read if they exist, and the files given in the EnvFile option.
+func (c *Command) dotEnvFiles() (optional, given []string) {
+	if dd := c.GetOpt(path2.Path{c.Name, "DataDir"}); dd != nil {
+		optional = append(optional,
+			filepath.Join(dd.Expanded(), DotEnvFilename))
+	}
+	if wd, err := os.Getwd(); err == nil {
+		optional = append(optional, filepath.Join(wd, DotEnvFilename))
+	}
+	if ef, ok := c.GetOpt(path2.Path{c.Name, "EnvFile"}).(*list.Opt); ok {
+		for _, f := range ef.Items() {
+			if f = strings.TrimSpace(f); f != "" {
+				given = append(given, f)
+			}
+		}
+	}
+	return
+}
+
+// LoadDotEnv reads the dotenv files of the application. The files in the
+// data and working directories are optional, but the files named in the
+// EnvFile option must exist.
+func (c *Command) LoadDotEnv() (d *DotEnv, err error) {
+	d = NewDotEnv()
+	optional, given := c.dotEnvFiles()
+	seen := make(map[string]bool)
+	for i, f := range append(optional, given...) {
+		if abs, e := filepath.Abs(f); e == nil {
+			if seen[abs] {
+				continue
+			}
+			seen[abs] = true
+		}
+		if err = d.ReadFile(f); err != nil {
+			if os.IsNotExist(err) && i < len(optional) {
+				err = nil
+				continue
+			}
+			return
+		}
+		log.D.Ln("read environment from", f)
+	}
+	return
+}
+
+// EnvOverride returns true if values from dotenv files should replace those
+// in the process environment.
+func (c *Command) EnvOverride() bool {
+	if op := c.GetOpt(path2.Path{c.Name, "EnvFileOverride"}); op != nil {
+		return op.Value().Bool()
+	}
+	return false
+}
+
+// ExportEnv writes the values of the options whose tags match a tag
+// expression, see ParseTagQuery, as a dotenv file that LoadDotEnv and shells
+// read. As in the configuration file, secrets are only written if they are
+// saved as they are, see secret.Opt.SavesValue. It must be called on the root
+// Command.
+func (c *Command) ExportEnv(expr string) (text []byte, err error) {
+	var q TagQuery
+	if q, err = ParseTagQuery(expr); err != nil {
+		return
+	}
+	var b strings.Builder
+	for _, e := range c.GetEnvs() {
+		if !q.Match(e.Opt.Meta().Tags()) {
+			continue
+		}
+		v := c.savedValue(e.Opt)
+		if sec, ok := e.Opt.(*secret.Opt); ok && !sec.SavesValue(v) {
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", e.Vars()[0], dotEnvQuote(v))
+	}
+	return []byte(b.String()), nil
+}
+
+// dotEnvQuote quotes a value for a dotenv file if it needs to be, with single
+// quotes so it is taken literally, or double quotes if it has single quotes.
+func dotEnvQuote(v string) string {
+	plain := v != ""
+	for _, r := range v {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || strings.ContainsRune("-_.,:/@+=%", r)) {
+			plain = false
+			break
+		}
+	}
+	switch {
+	case plain:
+		return v
+	case !strings.ContainsRune(v, '\''):
+		return "'" + v + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`,
+		"\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(v) + `"`
+}
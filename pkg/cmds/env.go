@@ -1,47 +1,247 @@
 package cmds
 
 import (
+	"fmt"
 	"os"
 	"sort"
 	"strings"
 
 	"github.com/cybriq/proc/pkg/opts/config"
 	"github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
 )
 
+// Env is an option and the environment variables it is read from. If Var is
+// empty the name is derived from the path with EnvName. Source records where
+// the value was found when it was loaded.
 type Env struct {
-	Name path.Path
-	Opt  config.Option
+	Name    path.Path
+	Var     string
+	Aliases []string
+	Source  string
+	Opt     config.Option
 }
 
 type Envs []Env
 
+// EnvSanitise makes a string usable as part of an environment variable name,
+// by upper casing it and replacing every character other than letters, digits
+// and underscore with an underscore. A leading digit is prefixed with an
+// underscore.
+func EnvSanitise(s string) string {
+	b := []byte(strings.ToUpper(s))
+	for i := range b {
+		switch {
+		case b[i] >= 'A' && b[i] <= 'Z', b[i] >= '0' && b[i] <= '9',
+			b[i] == '_':
+		default:
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// EnvName returns the environment variable name for an option path.
+func EnvName(p path.Path) string {
+	var name []string
+	for j := range p {
+		name = append(name, EnvSanitise(p[j]))
+	}
+	return strings.Join(name, "_")
+}
+
+// Prefix returns the prefix of the environment variables of the application,
+// which is EnvPrefix if it is set, or otherwise the name of the Command,
+// which should be the root.
+func (c *Command) Prefix() string {
+	if c.EnvPrefix != "" {
+		return EnvSanitise(c.EnvPrefix)
+	}
+	return EnvSanitise(c.Name)
+}
+
+// EnvVar returns the environment variable name for an option path starting
+// with the name of the root Command, which it must be called on. An option
+// can replace the name with the Env field of its metadata.
+func (c *Command) EnvVar(p path.Path) string {
+	if op := c.GetOpt(p); op != nil {
+		if env := op.Meta().Env(); env != "" {
+			return env
+		}
+	}
+	if len(p) < 1 {
+		return ""
+	}
+	return EnvName(append(path.Path{c.Prefix()}, p[1:]...))
+}
+
+// Vars returns the primary environment variable name followed by the aliases.
+func (e Env) Vars() (vars []string) {
+	if e.Var != "" {
+		vars = append(vars, e.Var)
+	} else {
+		vars = append(vars, EnvName(e.Name))
+	}
+	return append(vars, e.Aliases...)
+}
+
 func (e Envs) ForEach(fn func(env string, opt config.Option) (err error)) (err error) {
 	for i := range e {
-		var name []string
-		for j := range e[i].Name {
-			name = append(name, strings.ToUpper(e[i].Name[j]))
-		}
-		err = fn(strings.Join(name, "_"), e[i].Opt)
+		err = fn(e[i].Vars()[0], e[i].Opt)
 		if err != nil {
+			err = fmt.Errorf("%s: %w", e[i].Name, err)
 			return
 		}
 	}
 	return
 }
 
+// Collisions returns an error listing every environment variable name that
+// more than one option would be read from.
+func (e Envs) Collisions() (err error) {
+	owners := make(map[string][]string)
+	var names []string
+	for i := range e {
+		for _, v := range e[i].Vars() {
+			if _, ok := owners[v]; !ok {
+				names = append(names, v)
+			}
+			owners[v] = append(owners[v], e[i].Name.String())
+		}
+	}
+	sort.Strings(names)
+	var msgs []string
+	for _, v := range names {
+		if len(owners[v]) > 1 {
+			msgs = append(msgs, fmt.Sprintf("%s is used by %s", v,
+				strings.Join(owners[v], ", ")))
+		}
+	}
+	if len(msgs) > 0 {
+		err = fmt.Errorf("environment variable collision:\n%s",
+			strings.Join(msgs, "\n"))
+	}
+	return
+}
+
+// LoadFromEnvironment sets every option that has one of its environment
+// variables set in the process environment.
 func (e Envs) LoadFromEnvironment() (err error) {
-	err = e.ForEach(func(env string, opt config.Option) (err error) {
-		v, exists := os.LookupEnv(env)
+	return e.Load(ProcessEnv)
+}
+
+// EnvLookup finds the value of an environment variable and where it was set.
+type EnvLookup func(name string) (value, source string, ok bool)
+
+// ProcessEnv is an EnvLookup for the process environment.
+func ProcessEnv(name string) (value, source string, ok bool) {
+	value, ok = os.LookupEnv(name)
+	return value, "environment", ok
+}
+
+// SetEnvLookup sets where the profiles and the passphrase of the
+// configuration are looked for in the environment while the configuration is
+// loaded, which is the process environment if it is not set, so they can be
+// given in dotenv files. It must be called on the root Command.
+func (c *Command) SetEnvLookup(lookup EnvLookup) {
+	c.env = lookup
+}
+
+// lookupEnv finds an environment variable with the EnvLookup of the root.
+func (c *Command) lookupEnv(name string) (value, source string, ok bool) {
+	if lookup := c.root().env; lookup != nil {
+		return lookup(name)
+	}
+	return ProcessEnv(name)
+}
+
+// Load sets every option that has one of its environment variables found by
+// the lookup, and records where the value came from in Source. The primary
+// name takes precedence over the aliases, which are tried in order.
+func (e Envs) Load(lookup EnvLookup) (err error) {
+	for i := range e {
+		var env, v, src string
+		var exists bool
+		for _, env = range e[i].Vars() {
+			if v, src, exists = lookup(env); exists {
+				break
+			}
+		}
+		opt := e[i].Opt
 		if exists {
-			log.I.S(v, env, opt)
 			err = opt.FromString(v)
 			if log.D.Chk(err) {
-				return err
+				return fmt.Errorf("%s: from environment variable %s (%s): %w",
+					e[i].Name, env, src, err)
 			}
+			e[i].Source = src
+			// the option prints its own value so secrets stay redacted
+			log.I.F("%s=%s (from %s)", env, opt, src)
 		}
+	}
+	return
+}
+
+// Unknown returns a Diagnostic for every variable that starts with the prefix
+// and is not read by any option, suggesting the closest name that is. The
+// sources map the variable names to where they were set.
+func (e Envs) Unknown(prefix string, sources map[string]string) (
+	diags Diagnostics) {
+
+	known := make(map[string]bool)
+	var names []string
+	for i := range e {
+		for _, v := range e[i].Vars() {
+			known[v] = true
+			names = append(names, v)
+		}
+	}
+	var found []string
+	for name := range sources {
+		if strings.HasPrefix(name, prefix+"_") && !known[name] {
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+	for _, name := range found {
+		msg := fmt.Sprintf("environment variable %s is not an option", name)
+		if s := util.Suggest(name, names); s != "" {
+			msg += fmt.Sprintf(", did you mean %s?", s)
+		}
+		diags = append(diags, Diagnostic{File: sources[name], Message: msg})
+	}
+	return
+}
+
+// CheckEnv looks for variables in the process environment and the dotenv
+// files with the prefix of the application that are not read by any option.
+// In strict mode these are returned as an error, otherwise they are logged as
+// warnings. It must be called on the root Command.
+func (c *Command) CheckEnv(e Envs, d *DotEnv) (err error) {
+	sources := make(map[string]string)
+	if d != nil {
+		for name := range d.Sources {
+			sources[name] = d.Sources[name]
+		}
+	}
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			sources[kv[:i]] = "environment"
+		}
+	}
+	diags := e.Unknown(c.Prefix(), sources)
+	if len(diags) < 1 {
 		return
-	})
+	}
+	if c.strict() {
+		return diags
+	}
+	for i := range diags {
+		log.W.Ln(diags[i])
+	}
 	return
 }
 
@@ -75,27 +275,37 @@ func (e Envs) Swap(i, j int) {
 
 // GetEnvs walks a Command tree and returns a slice containing all environment
 // variable names and the related config.Option.
+//
+// When called without a path on the root Command the names of the variables
+// are filled in using its prefix and the metadata of the options.
 func (c *Command) GetEnvs(path ...string) (envs Envs) {
-	if path == nil {
+	root := path == nil
+	if root {
 		path = []string{c.Name}
 	}
 	for {
 		for i := range c.Configs {
 			envs = append(envs, Env{
-				Name: append(path, i),
+				Name: append(path[:len(path):len(path)], i),
 				Opt:  c.Configs[i],
 			})
 		}
 		if len(c.Commands) > 0 {
 			for i := range c.Commands {
 				envs = append(envs,
-					c.Commands[i].GetEnvs(
-						append(path, c.Commands[i].Name)...)...,
+					c.Commands[i].GetEnvs(append(path[:len(path):len(path)],
+						c.Commands[i].Name)...)...,
 				)
 			}
 		}
 		break
 	}
+	if root {
+		for i := range envs {
+			envs[i].Var = c.EnvVar(envs[i].Name)
+			envs[i].Aliases = envs[i].Opt.Meta().EnvAliases()
+		}
+	}
 	sort.Sort(envs)
 	return
 }
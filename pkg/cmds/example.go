@@ -5,15 +5,23 @@ import (
 	"encoding/base32"
 	"fmt"
 	"runtime"
+	"time"
 
 	integer "github.com/cybriq/proc/pkg/opts/Integer"
+	"github.com/cybriq/proc/pkg/opts/cidr"
 	"github.com/cybriq/proc/pkg/opts/config"
 	"github.com/cybriq/proc/pkg/opts/duration"
+	"github.com/cybriq/proc/pkg/opts/endpoint"
+	"github.com/cybriq/proc/pkg/opts/enum"
 	"github.com/cybriq/proc/pkg/opts/float"
 	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/mapopt"
 	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/opts/secret"
+	"github.com/cybriq/proc/pkg/opts/size"
 	"github.com/cybriq/proc/pkg/opts/text"
 	"github.com/cybriq/proc/pkg/opts/toggle"
+	"github.com/cybriq/proc/pkg/opts/urlopt"
 )
 
 const lorem = `
@@ -33,6 +41,8 @@ func GetExampleCommands() (c *Command) {
 		Description:   "All in one everything for parallelcoin",
 		Documentation: lorem,
 		Default:       Tags("gui"),
+		Groups: Tags("Servers", "Clients", "node", "wallet", "ctl",
+			"Configuration", "Environment", "Logging"),
 		Configs: config.Opts{
 			"AutoPorts": toggle.New(meta.Data{
 				Label:         "Automatic Ports",
@@ -107,7 +117,7 @@ func GetExampleCommands() (c *Command) {
 				Options:       Tags("en"),
 				Default:       "en",
 			}),
-			"LimitPass": text.New(meta.Data{
+			"LimitPass": secret.New(meta.Data{
 				Aliases:       Tags("LP"),
 				Tags:          Tags("node", "wallet"),
 				Label:         "Limit Password",
@@ -144,7 +154,7 @@ func GetExampleCommands() (c *Command) {
 				Documentation: lorem,
 				Default:       "false",
 			}),
-			"Password": text.New(meta.Data{
+			"Password": secret.New(meta.Data{
 				Aliases:       Tags("PW"),
 				Tags:          Tags("node", "wallet"),
 				Label:         "Password",
@@ -201,6 +211,7 @@ func GetExampleCommands() (c *Command) {
 			"Save": toggle.New(meta.Data{
 				Aliases:       Tags("SV"),
 				Label:         "Save Configuration",
+				Group:         "Configuration",
 				Description:   "save opts given on commandline",
 				Documentation: lorem,
 				Default:       "false",
@@ -258,6 +269,7 @@ func GetExampleCommands() (c *Command) {
 		Commands: Commands{
 			{
 				Name:          "gui",
+				Group:         "Clients",
 				Description:   "ParallelCoin GUI Wallet/Miner/Explorer",
 				Documentation: lorem,
 				Configs: config.Opts{
@@ -278,11 +290,14 @@ func GetExampleCommands() (c *Command) {
 			},
 			{
 				Name:          "ctl",
+				Group:         "Clients",
 				Description:   "command line wallet and chain RPC client",
 				Documentation: lorem,
 			},
 			{
 				Name:          "node",
+				Group:         "Servers",
+				Order:         1,
 				Description:   "ParallelCoin blockchain node",
 				Documentation: lorem,
 				Entrypoint: func(c *Command, args []string) error {
@@ -292,26 +307,32 @@ func GetExampleCommands() (c *Command) {
 				Commands: []*Command{
 					{
 						Name:          "dropaddrindex",
+						Group:         "Indexes",
 						Description:   "drop the address database index",
 						Documentation: lorem,
 					},
 					{
 						Name:          "droptxindex",
+						Group:         "Indexes",
 						Description:   "drop the transaction database index",
 						Documentation: lorem,
 					},
 					{
 						Name:          "dropcfindex",
+						Group:         "Indexes",
 						Description:   "drop the cfilter database index",
 						Documentation: lorem,
 					},
 					{
 						Name:          "dropindexes",
+						Group:         "Indexes",
+						Order:         1,
 						Description:   "drop all of the indexes",
 						Documentation: lorem,
 					},
 					{
 						Name:          "resetchain",
+						Group:         "Chain",
 						Description:   "deletes the current blockchain cache to force redownload",
 						Documentation: lorem,
 					},
@@ -346,6 +367,7 @@ func GetExampleCommands() (c *Command) {
 						Description:   "how long a ban of a misbehaving peer lasts",
 						Documentation: lorem,
 						Default:       "24h0m0s",
+						Validators:    meta.Validators(meta.Min(time.Second)),
 					}),
 					"BanThreshold": integer.New(meta.Data{
 						Aliases:       Tags("BT"),
@@ -418,7 +440,15 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "false",
 					}),
-					"DbType": text.New(meta.Data{
+					"DbCache": size.New(meta.Data{
+						Aliases:       Tags("DBC"),
+						Tags:          Tags("node"),
+						Label:         "Database Cache",
+						Description:   "memory used to cache the database, such as 512MiB or 1.5GB",
+						Documentation: lorem,
+						Default:       "256MiB",
+					}, size.Clamp("4MiB", "64GiB")),
+					"DbType": enum.New(meta.Data{
 						Aliases: Tags("DB"),
 						Tags:    Tags("node"),
 						Label:   "Database Type",
@@ -467,6 +497,13 @@ func GetExampleCommands() (c *Command) {
 						Description:   "extra addresses to tell peers they can connect to",
 						Documentation: lorem,
 					}),
+					"FeeEstimatorURL": urlopt.New(meta.Data{
+						Aliases:       Tags("FEU"),
+						Tags:          Tags("node"),
+						Label:         "Fee Estimator URL",
+						Description:   "web service used to estimate transaction fees, if any",
+						Documentation: lorem,
+					}).Schemes("http", "https"),
 					"FreeTxRelayLimit": float.New(meta.Data{
 						Aliases:       Tags("LR"),
 						Tags:          Tags("node"),
@@ -497,6 +534,7 @@ func GetExampleCommands() (c *Command) {
 						Description:   "maximum number of peers to hold connections with",
 						Documentation: lorem,
 						Default:       "25",
+						Validators:    meta.Validators(meta.Range(0, 1000).Clamped()),
 					}),
 					"MinRelayTxFee": float.New(meta.Data{
 						Aliases:       Tags("MRTF"),
@@ -506,7 +544,7 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "0.00001000",
 					}),
-					"Network": text.New(meta.Data{
+					"Network": enum.New(meta.Data{
 						Aliases:     Tags("NW"),
 						Tags:        Tags("node", "wallet"),
 						Label:       "Network",
@@ -568,7 +606,7 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "127.0.0.1:1108",
 					}),
-					"OnionProxyPass": text.New(meta.Data{
+					"OnionProxyPass": secret.New(meta.Data{
 						Aliases:       Tags("OPW"),
 						Tags:          Tags("node"),
 						Label:         "Onion Proxy Password",
@@ -592,15 +630,15 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "127.0.0.1:11048",
 					}),
-					"P2PListeners": list.New(meta.Data{
+					"P2PListeners": endpoint.New(meta.Data{
 						Aliases:       Tags("LA"),
 						Tags:          Tags("node"),
 						Label:         "P2PListeners",
 						Description:   "list of addresses to bind the node listener to",
 						Documentation: lorem,
 						Default:       "127.0.0.1:11048,127.0.0.11:11048",
-					}),
-					"ProxyAddress": text.New(meta.Data{
+					}).Multi().DefaultPort("11047"),
+					"ProxyAddress": endpoint.New(meta.Data{
 						Aliases:       Tags("PA"),
 						Tags:          Tags("node"),
 						Label:         "Proxy",
@@ -608,13 +646,14 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "127.0.0.1:8989",
 					}),
-					"ProxyPass": text.New(meta.Data{
+					"ProxyPass": secret.New(meta.Data{
 						Aliases:       Tags("PPW"),
 						Tags:          Tags("node"),
 						Label:         "Proxy Pass",
 						Description:   "proxy password, if required",
 						Documentation: lorem,
 						Default:       genPassword(),
+						EnvAliases:    Tags("POD_PROXY_PASS"),
 					}),
 					"ProxyUser": text.New(meta.Data{
 						Aliases:       Tags("PU"),
@@ -640,7 +679,7 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "false",
 					}),
-					"RPCConnect": text.New(meta.Data{
+					"RPCConnect": endpoint.New(meta.Data{
 						Aliases: Tags("RA"),
 						Tags:    Tags("node"),
 						Label:   "RPC Connect",
@@ -648,15 +687,15 @@ func GetExampleCommands() (c *Command) {
 							" to connect to",
 						Documentation: lorem,
 						Default:       "127.0.0.1:11048",
-					}),
-					"RPCListeners": list.New(meta.Data{
+					}).DefaultPort("11048"),
+					"RPCListeners": endpoint.New(meta.Data{
 						Aliases:       Tags("RL"),
 						Tags:          Tags("node"),
 						Label:         "Node RPC Listeners",
 						Description:   "addresses to listen for RPC connections",
 						Documentation: lorem,
 						Default:       "127.0.0.1:11048",
-					}),
+					}).Multi().DefaultPort("11048"),
 					"RPCMaxClients": integer.New(meta.Data{
 						Aliases:       Tags("RMXC"),
 						Tags:          Tags("node"),
@@ -665,6 +704,13 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "10",
 					}),
+					"PeerWeights": mapopt.New(meta.Data{
+						Aliases:       Tags("PWT"),
+						Tags:          Tags("node"),
+						Label:         "Peer Weights",
+						Description:   "preference for peers by address, higher is preferred",
+						Documentation: lorem,
+					}).Of(meta.Integer),
 					"RPCMaxConcurrentReqs": integer.New(meta.Data{
 						Aliases:       Tags("RMCR"),
 						Tags:          Tags("node"),
@@ -742,23 +788,26 @@ func GetExampleCommands() (c *Command) {
 						Label:         "User Agent Comments",
 						Description:   "comment to add to the user agent -- See BIP 14 for more information",
 						Documentation: lorem,
+						Validators:    meta.Validators(meta.Regex(`^[^/:()]*$`)),
 					}),
 					"UUID": integer.New(meta.Data{
 						Label:         "UUID",
 						Description:   "instance unique id (32bit random value) (json mangles big 64 bit integers due to float64 numbers)",
 						Documentation: lorem,
 					}),
-					"Whitelists": list.New(meta.Data{
+					"Whitelists": cidr.New(meta.Data{
 						Aliases:       Tags("WL"),
 						Tags:          Tags("node"),
 						Label:         "Whitelists",
 						Description:   "peers that you don't want to ever ban",
 						Documentation: lorem,
-					}),
+					}).Multi(),
 				},
 			},
 			{
 				Name:          "wallet",
+				Group:         "Servers",
+				Order:         2,
 				Description:   "run the wallet server (requires a chain node to function)",
 				Documentation: lorem,
 				Commands: []*Command{
@@ -777,7 +826,7 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       "~/.pod/mainnet/wallet.db",
 					}),
-					"Pass": text.New(meta.Data{
+					"Pass": secret.New(meta.Data{
 						Aliases: Tags("WPW"),
 						Label:   "Wallet Pass",
 						Tags:    Tags("wallet"),
@@ -785,14 +834,14 @@ func GetExampleCommands() (c *Command) {
 							" so give on command line or in environment POD_WALLETPASS",
 						Documentation: lorem,
 						Default:       genPassword(),
-					}),
-					"RPCListeners": list.New(meta.Data{
+					}).SaveAs(secret.SaveOmit),
+					"RPCListeners": endpoint.New(meta.Data{
 						Aliases:       Tags("WRL"),
 						Tags:          Tags("wallet"),
 						Label:         "Wallet RPC Listeners",
 						Description:   "addresses for wallet RPC server to listen on",
 						Documentation: lorem,
-					}),
+					}).Multi().DefaultPort("11046"),
 					"RPCMaxClients": integer.New(
 						meta.Data{
 							Aliases:       Tags("WRMC"),
@@ -821,6 +870,7 @@ func GetExampleCommands() (c *Command) {
 			},
 			{
 				Name:          "kopach",
+				Group:         "Servers",
 				Description:   "standalone multicast miner for easy mining farm deployment",
 				Documentation: lorem,
 				Configs: config.Opts{
@@ -840,7 +890,7 @@ func GetExampleCommands() (c *Command) {
 						Documentation: lorem,
 						Default:       fmt.Sprint(runtime.NumCPU() / 2),
 					}),
-					"MulticastPass": text.New(meta.Data{
+					"MulticastPass": secret.New(meta.Data{
 						Aliases:       Tags("PM"),
 						Tags:          Tags("node", "kopach"),
 						Label:         "Multicast Pass",
@@ -852,6 +902,7 @@ func GetExampleCommands() (c *Command) {
 			},
 			{
 				Name:        "worker",
+				Group:       "Servers",
 				Description: "single thread worker process, normally started by kopach",
 			},
 		},
@@ -0,0 +1,142 @@
+package cmds
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cybriq/proc/pkg/util"
+)
+
+// Section is a heading of the help output and the names of the commands or
+// options under it, in the order they are shown.
+type Section struct {
+	Name  string // empty if nothing is grouped
+	Names []string
+}
+
+// sectionItem is a command or option being placed in a Section.
+type sectionItem struct {
+	name, group, label string
+	order              int
+}
+
+// OptionSections returns the options of the Command in help sections. An
+// option is in the section of its Group, or if it has none, of its first tag.
+// Within a section the options with an Order come first, lowest first, and
+// then the rest by their Label, or their name if they have none.
+func (c *Command) OptionSections() []Section {
+	items := make([]sectionItem, 0, len(c.Configs))
+	for name, op := range c.Configs {
+		m := op.Meta()
+		it := sectionItem{name, m.Group(), m.Label(), m.Order()}
+		if tags := m.Tags(); it.group == "" && len(tags) > 0 {
+			it.group = tags[0]
+		}
+		items = append(items, it)
+	}
+	return c.sections(items)
+}
+
+// CommandSections returns the subcommands of the Command in help sections by
+// their Group, leaving out Hidden ones. Within a section the commands with an
+// Order come first, lowest first, and then the rest by name.
+func (c *Command) CommandSections() []Section {
+	items := make([]sectionItem, 0, len(c.Commands))
+	for _, cm := range c.Commands {
+		if cm.Hidden {
+			continue
+		}
+		items = append(items, sectionItem{cm.Name, cm.Group, "", cm.Order})
+	}
+	return c.sections(items)
+}
+
+// sections sorts items into sections, in the order of the Groups of the
+// Command, or of the nearest parent that has Groups, then the other sections
+// by name, and last the items with no group, headed Other. If no item has a
+// group there is one section with no name.
+func (c *Command) sections(items []sectionItem) (s []Section) {
+	var groups []string
+	for p := c; p != nil && groups == nil; p = p.Parent {
+		groups = p.Groups
+	}
+	rank := make(map[string]int)
+	for i := range groups {
+		if _, ok := rank[util.Norm(groups[i])]; !ok {
+			rank[util.Norm(groups[i])] = i + 1
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if ga, gb := util.Norm(a.group), util.Norm(b.group); ga != gb {
+			ra, rb := rank[ga], rank[gb]
+			switch {
+			case ga == "" || gb == "":
+				return gb == ""
+			case ra > 0 && rb > 0:
+				return ra < rb
+			case ra > 0 || rb > 0:
+				return ra > 0
+			}
+			return ga < gb
+		}
+		if a.order != b.order {
+			if a.order == 0 || b.order == 0 {
+				return b.order == 0
+			}
+			return a.order < b.order
+		}
+		la, lb := a.label, b.label
+		if la == "" {
+			la = a.name
+		}
+		if lb == "" {
+			lb = b.name
+		}
+		if util.Norm(la) != util.Norm(lb) {
+			return util.Norm(la) < util.Norm(lb)
+		}
+		return a.name < b.name
+	})
+	grouped := false
+	for i := range items {
+		grouped = grouped || items[i].group != ""
+	}
+	for _, it := range items {
+		name := it.group
+		switch r := rank[util.Norm(name)]; {
+		case r > 0:
+			// the heading is spelled as it was declared
+			name = groups[r-1]
+		case grouped && name == "":
+			name = "Other"
+		}
+		if len(s) < 1 || util.Norm(s[len(s)-1].Name) != util.Norm(name) {
+			s = append(s, Section{Name: name})
+		}
+		s[len(s)-1].Names = append(s[len(s)-1].Names, it.name)
+	}
+	return
+}
+
+// heading returns the heading of a Section in help output, which is nothing
+// if it has no name, and begins with a blank line if it is not the first.
+func (s Section) heading(first bool) (h string) {
+	if s.Name == "" {
+		return
+	}
+	if h = fmt.Sprintf("\t%s:\n", s.Name); !first {
+		h = "\n" + h
+	}
+	return
+}
+
+// subcommand returns the subcommand with a name, or nil if there is none.
+func (c *Command) subcommand(name string) *Command {
+	for _, cm := range c.Commands {
+		if cm.Name == name {
+			return cm
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"text/tabwriter"
 
 	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/enum"
 	"github.com/cybriq/proc/pkg/util"
 )
 
@@ -29,7 +30,10 @@ func Help() (h *Command) {
 			"Use their full path and the full " +
 			"documentation for the item will be shown.\n\n" +
 			"Note that in all cases, options are only recognised after their\n" +
-			"related subcommand.",
+			"related subcommand.\n\n" +
+			"With --tag=<expression> the options whose tags match the " +
+			"expression are\nlisted, such as --tag='node && !tls', and " +
+			"other terms must be in their\npaths.",
 		Entrypoint: HelpEntrypoint,
 		Parent:     nil,
 		Commands:   nil,
@@ -51,6 +55,29 @@ func IndentTextBlock(s string, tabs int) (o string) {
 	return strings.Join(split, "\n")
 }
 
+// choices returns the valid values of an option for help output, with the
+// aliases of each if it is an enum.
+func choices(op config.Option) (ch []string) {
+	aliases := make(map[string][]string)
+	e, isEnum := op.(*enum.Opt)
+	if isEnum {
+		for a, c := range e.Aliases() {
+			aliases[c] = append(aliases[c], a)
+		}
+	}
+	for _, c := range op.Meta().Options() {
+		if a := aliases[c]; len(a) > 0 {
+			sort.Strings(a)
+			c += " (also " + strings.Join(a, ", ") + ")"
+		}
+		ch = append(ch, c)
+	}
+	if isEnum && e.IsMulti() && len(ch) > 0 {
+		ch = append(ch, "(any number of these, separated by commas)")
+	}
+	return
+}
+
 type CommandInfo struct {
 	name, description string
 }
@@ -66,6 +93,25 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 		// no args given, just print top level general help
 		return
 	}
+	var tags, terms []string
+	for i := 0; i < len(args); i++ {
+		a := strings.TrimLeft(args[i], "-")
+		name, value, hasValue := strings.Cut(a, "=")
+		if a == args[i] || util.Norm(name) != "tag" {
+			terms = append(terms, args[i])
+			continue
+		}
+		if !hasValue {
+			if i++; i >= len(args) {
+				return fmt.Errorf("--tag needs a value")
+			}
+			value = args[i]
+		}
+		tags = append(tags, value)
+	}
+	if len(tags) > 0 {
+		return helpTags(c, tags, terms)
+	}
 
 	foundCommands := &[]*Command{}
 	fops := make(map[string]config.Option)
@@ -74,8 +120,11 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 	foundOptionWhole := false
 	c.ForEach(func(cm *Command, depth int) bool {
 		for i := range args {
-			// check for match of current command name
-			if strings.Contains(util.Norm(cm.Name), util.Norm(args[i])) {
+			// check for match of current command name, hidden commands
+			// only matching their whole name
+			if strings.Contains(util.Norm(cm.Name), util.Norm(args[i])) &&
+				(!cm.Hidden || util.Norm(cm.Name) == util.Norm(args[i])) {
+
 				if util.Norm(cm.Name) == util.Norm(args[i]) {
 					if len(args) == 1 {
 						foundCommandWhole = true
@@ -122,15 +171,6 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 	case foundCommandWhole && len(args) == 1:
 		cm := (*foundCommands)[0]
 		// Print command help information
-		var outs CommandInfos
-		for i := range cm.Commands {
-			outs = append(outs,
-				&CommandInfo{
-					name:        cm.Commands[i].Name,
-					description: cm.Commands[i].Description,
-				})
-		}
-		sort.Sort(outs)
 		// out += fmt.Sprintf("\n%s - %s\n\n", cm.Path, cm.Description)
 		out += fmt.Sprintf(
 			"Help information for command '%s':\n\n",
@@ -139,22 +179,25 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 			IndentTextBlock(cm.Documentation, 1))
 		if len(cm.Commands) > 0 {
 			out += fmt.Sprintf("The commands are:\n\n")
-			for i := range outs {
-				def := ""
-				if len(cm.Default) > 0 {
-					if outs[i].name == cm.Default[len(cm.Default)-1] {
-						def = " *"
+			for i, sec := range cm.CommandSections() {
+				out += sec.heading(i == 0)
+				for _, name := range sec.Names {
+					def := ""
+					if len(cm.Default) > 0 {
+						if name == cm.Default[len(cm.Default)-1] {
+							def = " *"
+						}
 					}
-				}
-				if _, e := fmt.Fprintf(w, "\t%s %s%s\n",
-					outs[i].name, outs[i].description,
-					def); e != nil {
+					if _, e := fmt.Fprintf(w, "\t%s %s%s\n",
+						name, cm.subcommand(name).Description,
+						def); e != nil {
 
-					_, _ = fmt.Fprintln(os.Stderr, "error printing columns")
-				} else {
-					w.Flush()
-					out += b.String()
-					b.Reset()
+						_, _ = fmt.Fprintln(os.Stderr, "error printing columns")
+					} else {
+						w.Flush()
+						out += b.String()
+						b.Reset()
+					}
 				}
 			}
 			if cm.Default != nil {
@@ -170,24 +213,30 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 			out += fmt.Sprintf("\t-%s %v - %s (default: '%s')\n",
 				"flag", "[alias1 alias2]", "description", "default")
 			out += "\t\t(prefix '-' can also be '--', value can follow after space or with '=' and no space)\n\n"
-			var opts []string
-			for i := range c.Configs {
-				opts = append(opts, i)
-			}
-			sort.Strings(opts)
-			for i := range opts {
-				aliases := c.Configs[opts[i]].Meta().Aliases()
-				for j := range aliases {
-					aliases[j] = strings.ToLower(aliases[j])
-				}
-				var al string
-				if len(aliases) > 0 {
-					al = fmt.Sprint(aliases, " ")
+			for i, sec := range cm.OptionSections() {
+				out += sec.heading(i == 0)
+				for _, name := range sec.Names {
+					op := cm.Configs[name]
+					aliases := op.Meta().Aliases()
+					for j := range aliases {
+						aliases[j] = strings.ToLower(aliases[j])
+					}
+					var al string
+					if len(aliases) > 0 {
+						al = fmt.Sprint(aliases, " ")
+					}
+					out += fmt.Sprintf("\t-%s %v\n\t\t%s (default: '%s')\n",
+						strings.ToLower(name), al, op.Meta().Description(),
+						op.Meta().Default())
+					if ch := choices(op); len(ch) > 0 {
+						out += fmt.Sprintf("\t\tchoices: %s\n",
+							strings.Join(ch, ", "))
+					}
+					if v := op.Meta().Describe(); len(v) > 0 {
+						out += fmt.Sprintf("\t\tmust be: %s\n",
+							strings.Join(v, ", "))
+					}
 				}
-				out += fmt.Sprintf("\t-%s %v\n\t\t%s (default: '%s')\n", strings.ToLower(opts[i]),
-					al,
-					c.Configs[opts[i]].Meta().Description(),
-					c.Configs[opts[i]].Meta().Default())
 			}
 			out += fmt.Sprintf(
 				"\nUse 'help %s <option>' to get details on option.\n",
@@ -222,6 +271,17 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 			out += fmt.Sprintf("\t%s\n\n", om.Description())
 			out += fmt.Sprintf("Default:\n\n\t%s %s--%s=%s\n\n",
 				c.Name, path, strings.ToLower(i), om.Default())
+			if ch := choices(op); len(ch) > 0 {
+				out += fmt.Sprintf("Choices:\n\n\t%s\n\n",
+					strings.Join(ch, "\n\t"))
+			}
+			if v := om.Describe(); len(v) > 0 {
+				out += fmt.Sprintf("Validation:\n\n\t%s\n\n",
+					strings.Join(v, "\n\t"))
+			}
+			out += fmt.Sprintf("Environment:\n\n\t%s\n\n",
+				strings.Join(append([]string{c.EnvVar(op.Path().Child(i))},
+					om.EnvAliases()...), "\n\t"))
 			out += fmt.Sprintf("Documentation:\n\n%s\n\n",
 				IndentTextBlock(om.Documentation(), 1))
 		}
@@ -255,7 +315,12 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 		}
 		if len(*foundOptions) > 0 {
 			out += fmt.Sprintf("Options:\n\n")
+			names := make([]string, 0, len(*foundOptions))
 			for i := range *foundOptions {
+				names = append(names, i)
+			}
+			sort.Strings(names)
+			for _, i := range names {
 				op := (*foundOptions)[i]
 				om := op.Meta()
 				path := op.Path().TrimPrefix().String()
@@ -279,16 +344,6 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 		out += "Usage:\n\n"
 		out += fmt.Sprintf("\t%s [arguments] [<subcommand> [arguments]]\n\n",
 			cm.Name)
-		var outs CommandInfos
-		for i := range cm.Commands {
-			outs = append(outs,
-				&CommandInfo{
-					name:        cm.Commands[i].Name,
-					description: cm.Commands[i].Description,
-				})
-		}
-		sort.Sort(outs)
-		// log.I.S(outs)
 		plural := ""
 		pluralVerb := "is"
 		if len(c.Commands) > 1 {
@@ -301,18 +356,20 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 		// minwidth, tabwidth, padding, padchar, flags
 		w.Init(&b, 8, 8, 0, '\t', 0)
 		if len(c.Commands) > 0 {
-			for i := range outs {
-				def := ""
-				if len(cm.Default) > 0 {
-					if outs[i].name == cm.Default[len(cm.Default)-1] {
-						def = " *"
+			for i, sec := range cm.CommandSections() {
+				fmt.Fprint(w, sec.heading(i == 0))
+				for _, name := range sec.Names {
+					def := ""
+					if len(cm.Default) > 0 {
+						if name == cm.Default[len(cm.Default)-1] {
+							def = " *"
+						}
+					}
+					if _, e := fmt.Fprintf(w, "\t%s\t %s\n",
+						name+def, cm.subcommand(name).Description,
+					); e != nil {
+						_, _ = fmt.Fprintln(os.Stderr, "error printing columns")
 					}
-				}
-				if _, e := fmt.Fprintf(w, "\t%s\t %s\n",
-					outs[i].name+def, outs[i].description,
-				); e != nil {
-					_, _ = fmt.Fprintln(os.Stderr, "error printing columns")
-				} else {
 				}
 			}
 			w.Flush()
@@ -325,25 +382,23 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 			}
 		}
 		out += "Available configuration options at top level:\n\n"
-		var opts []string
-		for i := range c.Configs {
-			opts = append(opts, i)
-		}
-		sort.Strings(opts)
-		for i := range opts {
-			aliases := c.Configs[opts[i]].Meta().Aliases()
-			for j := range aliases {
-				aliases[j] = strings.ToLower(aliases[j])
-			}
-			var al string
-			if len(aliases) > 0 {
-				al = fmt.Sprint(aliases, " ")
+		for i, sec := range c.OptionSections() {
+			fmt.Fprint(w, sec.heading(i == 0))
+			for _, name := range sec.Names {
+				om := c.Configs[name].Meta()
+				aliases := om.Aliases()
+				for j := range aliases {
+					aliases[j] = strings.ToLower(aliases[j])
+				}
+				var al string
+				if len(aliases) > 0 {
+					al = fmt.Sprint(aliases, " ")
+				}
+				_, _ = fmt.Fprintf(w, "\t-%s\t%v\n",
+					strings.ToLower(name)+" "+al,
+					om.Description()+" - default: "+om.Default(),
+				)
 			}
-			_, _ = fmt.Fprintf(w, "\t-%s\t%v\n",
-				strings.ToLower(opts[i])+" "+al,
-				c.Configs[opts[i]].Meta().Description()+" - default: "+
-					c.Configs[opts[i]].Meta().Default(),
-			)
 		}
 		fmt.Fprint(w, "\n\tFormat of configuration items:\n\n")
 		fmt.Fprintf(w, "\t\t-%s\t%v\t\n",
@@ -361,3 +416,46 @@ func HelpEntrypoint(c *Command, args []string) (err error) {
 	fmt.Print(out)
 	return
 }
+
+// helpTags prints the options whose tags match one of the tag expressions,
+// and if there are search terms, whose paths contain all of them.
+func helpTags(c *Command, tags, terms []string) (err error) {
+	var q TagQuery
+	if q, err = tagQueries(tags); err != nil {
+		return
+	}
+	out := fmt.Sprintf("%s - %s\n\n", c.Name, c.Description)
+	out += fmt.Sprintf("Options with tags matching '%s':\n\n",
+		strings.Join(tags, "' or '"))
+	var b bytes.Buffer
+	w := new(tabwriter.Writer)
+	w.Init(&b, 8, 8, 0, '\t', 0)
+	found := 0
+	for _, t := range c.selectTags(q) {
+		matched := true
+		for i := range terms {
+			if !strings.Contains(util.Norm(t.Path.String()),
+				util.Norm(terms[i])) {
+
+				matched = false
+			}
+		}
+		if !matched {
+			continue
+		}
+		found++
+		om := t.Meta()
+		fmt.Fprintf(w, "\t%s -%s=%s\t%s [%s]\n", t.Path.Parent(),
+			strings.ToLower(t.Path[len(t.Path)-1]), om.Default(),
+			om.Description(), strings.Join(om.Tags(), " "))
+	}
+	w.Flush()
+	out += b.String()
+	if found == 0 {
+		out += "\tnone\n"
+	}
+	out += "\nUse 'config tags' to list the tags and the number of options " +
+		"with each.\n"
+	fmt.Print(out)
+	return
+}
@@ -0,0 +1,173 @@
+package cmds
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/appdata"
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	path2 "github.com/cybriq/proc/pkg/path"
+)
+
+// AppDataRef is the reference that expands to the default data directory of
+// the application.
+const AppDataRef = "appdata"
+
+// setInterpolators gives every option in the tree that supports it the
+// function that expands its ${name} references. It must be called on the
+// root Command.
+//
+// A reference can be:
+//
+//   - the name of an option of the same Command or of the root, ${DataDir}
+//   - the path of an option from the root separated by dots, ${node.RPCPort}
+//   - a reference with a registered scheme, such as ${env:HOME}
+//   - ${appdata}, the default data directory of the application
+func (c *Command) setInterpolators() {
+	c.ForEach(func(cmd *Command, _ int) bool {
+		for i := range cmd.Configs {
+			if o, ok := cmd.Configs[i].(opts.Interpolated); ok {
+				op := cmd.Configs[i]
+				o.SetInterpolator(func(s string) (string, error) {
+					return c.interpolate(op, s)
+				})
+			}
+		}
+		return true
+	}, 0, 0, c)
+}
+
+// interpolate expands the references in a value of an option. The values
+// referred to are expanded first, so references are resolved in dependency
+// order, and a reference back to the option is an error.
+func (c *Command) interpolate(op config.Option, s string) (string, error) {
+	if err := c.findCycle(op, s, nil); err != nil {
+		return "", err
+	}
+	return opts.Interpolate(s, func(name string) (string, error) {
+		return c.refValue(op, name)
+	})
+}
+
+// refValue returns the value of a reference in an option.
+func (c *Command) refValue(op config.Option, name string) (string, error) {
+	if strings.EqualFold(name, AppDataRef) {
+		return appdata.Dir(c.Name, false), nil
+	}
+	if v, isRef, err := opts.Resolve(name); isRef || err != nil {
+		return v, err
+	}
+	target, err := c.refOpt(op, name)
+	if err != nil {
+		return "", err
+	}
+	if target.Type() == meta.Secret {
+		return "", fmt.Errorf("${%s} refers to a secret, which cannot be "+
+			"used in other values", name)
+	}
+	return target.Expanded(), nil
+}
+
+// refOpt finds the option a reference names. A single name is looked for in
+// the Command of the option first, then in the root.
+func (c *Command) refOpt(op config.Option, name string) (o config.Option,
+	err error) {
+
+	parts := strings.Split(name, ".")
+	if len(parts) == 1 && op != nil {
+		if o = c.GetOpt(op.Path().Child(name)); o != nil {
+			return
+		}
+	}
+	if o = c.GetOpt(append(path2.Path{c.Name}, parts...)); o != nil {
+		return
+	}
+	return nil, fmt.Errorf("${%s} does not name an option", name)
+}
+
+// findCycle follows the references of a value through the raw values of the
+// options they name, and returns an error if it arrives back at an option
+// already visited.
+func (c *Command) findCycle(op config.Option, s string,
+	visited []config.Option) error {
+
+	for i := range visited {
+		if visited[i] == op {
+			var names []string
+			for _, v := range append(visited[i:], op) {
+				names = append(names, strings.Join(
+					v.Path().Child(c.optName(v)), "."))
+			}
+			return fmt.Errorf("references form a cycle: %s",
+				strings.Join(names, " -> "))
+		}
+	}
+	visited = append(visited, op)
+	for _, name := range opts.References(s) {
+		if opts.IsReference(name) || strings.EqualFold(name, AppDataRef) {
+			continue
+		}
+		target, err := c.refOpt(op, name)
+		if err != nil {
+			continue
+		}
+		if err = c.findCycle(target, target.String(), visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandReferrers runs the hooks of the options with ${name} references to an
+// option that changed, so their expanded values follow it, and then of the
+// options referring to those. The options passed through are given so a
+// cycle of references ends.
+func (c *Command) expandReferrers(op config.Option, through []config.Option) {
+	through = append(through, op)
+	c.ForEach(func(cmd *Command, _ int) bool {
+		for i := range cmd.Configs {
+			o := cmd.Configs[i]
+			if _, ok := o.(opts.Interpolated); !ok || !c.refersTo(o, op) {
+				continue
+			}
+			visited := false
+			for j := range through {
+				visited = visited || through[j] == o
+			}
+			if visited {
+				continue
+			}
+			log.E.Chk(o.RunHooks())
+			c.expandReferrers(o, through)
+		}
+		return true
+	}, 0, 0, c)
+}
+
+// refersTo returns true if the value of an option has a ${name} reference to
+// another option.
+func (c *Command) refersTo(op, target config.Option) bool {
+	for _, name := range opts.References(op.String()) {
+		if opts.IsReference(name) || strings.EqualFold(name, AppDataRef) {
+			continue
+		}
+		if o, err := c.refOpt(op, name); err == nil && o == target {
+			return true
+		}
+	}
+	return false
+}
+
+// optName returns the name of an option in its Command.
+func (c *Command) optName(op config.Option) string {
+	if cmd := c.GetCommand(op.Path().String()); cmd != nil {
+		for name := range cmd.Configs {
+			if cmd.Configs[name] == op {
+				return name
+			}
+		}
+	}
+	return "?"
+}
@@ -0,0 +1,236 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GenMan is a hidden top level command that writes the man pages of the
+// application, for packaging.
+func GenMan() (c *Command) {
+	c = &Command{
+		Name:        "gen-man",
+		Description: "write the man pages of the application",
+		Documentation: strings.TrimSpace(`
+Writes a man page for the application and one for each of its subcommands, such
+as pod123-node.1, into the directory, or the current directory if none is given,
+and prints the files written. The pages are the same each time they are made
+from the same commands and options, so they can be kept with the sources.
+`),
+		Args:   Tags("[directory]"),
+		Hidden: true,
+		Entrypoint: func(c *Command, args []string) (err error) {
+			if len(args) > 1 {
+				return fmt.Errorf("gen-man takes one directory")
+			}
+			dir := "."
+			if len(args) == 1 {
+				dir = args[0]
+			}
+			var files []string
+			if files, err = c.WriteManPages(dir); err != nil {
+				return
+			}
+			for i := range files {
+				fmt.Println(files[i])
+			}
+			return
+		},
+	}
+	return
+}
+
+// ManPage is a man page in roff format, with the file name it is installed
+// as, such as pod123-node.1.
+type ManPage struct {
+	Name string
+	Text []byte
+}
+
+// ManPages returns the man pages of the Command and of each of its
+// subcommands, except Hidden ones, parents before their subcommands. The
+// Command must be initialised with Init.
+func (c *Command) ManPages() (pages []ManPage) {
+	pages = append(pages, ManPage{manName(c.Path) + ".1", c.ManPage()})
+	for _, cm := range c.Commands {
+		if !cm.Hidden {
+			pages = append(pages, cm.ManPages()...)
+		}
+	}
+	return
+}
+
+// WriteManPages writes the pages of ManPages into a directory, which is
+// created if it does not exist, returning the paths of the files.
+func (c *Command) WriteManPages(dir string) (files []string, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	for _, p := range c.ManPages() {
+		f := filepath.Join(dir, p.Name)
+		if err = os.WriteFile(f, p.Text, 0644); err != nil {
+			return
+		}
+		files = append(files, f)
+	}
+	return
+}
+
+// ManPage returns the man page of the Command, with its documentation, the
+// positional arguments, subcommands and default subcommand, and each option
+// with its aliases, type, default and environment variables.
+func (c *Command) ManPage() []byte {
+	var b bytes.Buffer
+	r := c.root()
+	name := manName(c.Path)
+	fmt.Fprintf(&b, ".TH \"%s\" \"1\" \"\" \"%s\" \"%s manual\"\n",
+		roffEscape(strings.ToUpper(name)), roffEscape(r.Name),
+		roffEscape(r.Name))
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", roffEscape(name),
+		roffEscape(c.Description))
+	sections := c.CommandSections()
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s\n", roffEscape(strings.Join(c.Path,
+		" ")))
+	if len(c.Configs) > 0 {
+		b.WriteString("[\\fIoptions\\fR]\n")
+	}
+	if len(sections) > 0 {
+		b.WriteString("[\\fIsubcommand\\fR]\n")
+	}
+	for i := range c.Args {
+		fmt.Fprintf(&b, "\\fI%s\\fR\n", roffEscape(c.Args[i]))
+	}
+	if c.Documentation != "" {
+		b.WriteString(".SH DESCRIPTION\n")
+		roffParagraphs(&b, c.Documentation, ".PP")
+	}
+	if len(sections) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, sec := range sections {
+			if sec.Name != "" {
+				fmt.Fprintf(&b, ".SS %s\n", roffEscape(sec.Name))
+			}
+			for _, n := range sec.Names {
+				fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", roffEscape(n),
+					roffEscape(c.subcommand(n).Description))
+			}
+		}
+		if len(c.Default) > 0 {
+			fmt.Fprintf(&b, ".PP\nWithout a subcommand, \\fB%s\\fR is run.\n",
+				roffEscape(c.Default[len(c.Default)-1]))
+		}
+	}
+	if len(c.Configs) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		for _, sec := range c.OptionSections() {
+			if sec.Name != "" {
+				fmt.Fprintf(&b, ".SS %s\n", roffEscape(sec.Name))
+			}
+			for _, n := range sec.Names {
+				c.manOption(&b, r, n)
+			}
+		}
+	}
+	var see []string
+	if c.Parent != nil {
+		see = append(see, manName(c.Parent.Path))
+	}
+	for _, sec := range sections {
+		for _, n := range sec.Names {
+			see = append(see, manName(c.Path.Child(n)))
+		}
+	}
+	if len(see) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		for i := range see {
+			sep := ","
+			if i == len(see)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&b, ".BR %s (1)%s\n", roffEscape(see[i]), sep)
+		}
+	}
+	return b.Bytes()
+}
+
+// manOption writes the entry of an option of the Command in a man page.
+func (c *Command) manOption(b *bytes.Buffer, r *Command, name string) {
+	op := c.Configs[name]
+	om := op.Meta()
+	flags := []string{name}
+	flags = append(flags, om.Aliases()...)
+	for i := range flags {
+		flags[i] = "\\fB\\-" + roffEscape(strings.ToLower(flags[i])) + "\\fR"
+	}
+	fmt.Fprintf(b, ".TP\n%s=\\fI%s\\fR\n", strings.Join(flags, ", "),
+		roffEscape(string(op.Type())))
+	if d := om.Description(); d != "" {
+		fmt.Fprintf(b, "%s\n", roffEscape(d))
+	}
+	if d := om.Documentation(); d != "" && d != om.Description() {
+		b.WriteString(".IP\n")
+		roffParagraphs(b, d, ".IP")
+	}
+	b.WriteString(".IP\n")
+	if d := om.Default(); d != "" {
+		fmt.Fprintf(b, "Default: %s\n.br\n", roffEscape(d))
+	}
+	if ch := choices(op); len(ch) > 0 {
+		fmt.Fprintf(b, "Choices: %s\n.br\n", roffEscape(strings.Join(ch,
+			", ")))
+	}
+	if v := om.Describe(); len(v) > 0 {
+		fmt.Fprintf(b, "Must be: %s\n.br\n", roffEscape(strings.Join(v,
+			", ")))
+	}
+	envs := append([]string{r.EnvVar(c.Path.Child(name))},
+		om.EnvAliases()...)
+	fmt.Fprintf(b, "Environment: %s\n", roffEscape(strings.Join(envs,
+		", ")))
+}
+
+// manName is the name of the man page of the Command at a path, such as
+// pod123-node.
+func manName(p []string) string {
+	return strings.Join(p, "-")
+}
+
+// roffEscape escapes text for roff, with the backslashes written as \e and
+// the hyphens as \- so they print as minus signs and can be searched for.
+// Text that would be read as a request at the start of a line is protected.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return strings.ReplaceAll(s, "-", `\-`)
+}
+
+// roffParagraphs writes documentation as roff paragraphs, separated with the
+// paragraph macro. Preformatted paragraphs are kept as they are written, and
+// indented.
+func roffParagraphs(b *bytes.Buffer, doc, macro string) {
+	for i, p := range paragraphs(doc) {
+		if i > 0 {
+			b.WriteString(macro + "\n")
+		}
+		if p.pre {
+			b.WriteString(".RS\n.nf\n")
+		}
+		for _, line := range p.lines {
+			// an indented line may begin with a request once trimmed
+			if line = roffEscape(line); strings.HasPrefix(line, ".") ||
+				strings.HasPrefix(line, "'") {
+
+				line = `\&` + line
+			}
+			b.WriteString(line + "\n")
+		}
+		if p.pre {
+			b.WriteString(".fi\n.RE\n")
+		}
+	}
+}
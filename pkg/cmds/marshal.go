@@ -3,21 +3,23 @@ package cmds
 import (
 	"encoding"
 	"fmt"
-	"io"
 	"os"
 	"sort"
 	"strings"
-	"time"
 
 	integer "github.com/cybriq/proc/pkg/opts/Integer"
-	"github.com/cybriq/proc/pkg/opts/duration"
+	"github.com/cybriq/proc/pkg/opts/cidr"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/endpoint"
+	"github.com/cybriq/proc/pkg/opts/enum"
 	"github.com/cybriq/proc/pkg/opts/float"
 	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/mapopt"
 	"github.com/cybriq/proc/pkg/opts/meta"
-	"github.com/cybriq/proc/pkg/opts/text"
-	"github.com/cybriq/proc/pkg/opts/toggle"
+	"github.com/cybriq/proc/pkg/opts/secret"
 	path2 "github.com/cybriq/proc/pkg/path"
 	"github.com/naoina/toml"
+	"github.com/naoina/toml/ast"
 )
 
 type Entry struct {
@@ -45,7 +47,11 @@ func (e Entries) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
 }
 
-func walk(p []string, v interface{}, in Entries) (o Entries) {
+// walk collects the values in nested tables. A table is a value rather than
+// a further level of nesting if leaf returns true for its path.
+func walk(p []string, v interface{}, in Entries,
+	leaf func(p path2.Path) bool) (o Entries) {
+
 	o = append(o, in...)
 	var parent []string
 	for i := range p {
@@ -56,10 +62,18 @@ func walk(p []string, v interface{}, in Entries) (o Entries) {
 		for i := range vv {
 			switch vvv := vv[i].(type) {
 			case map[string]interface{}:
-				o = walk(append(parent, i), vvv, o)
+				if leaf != nil && leaf(path2.Path(parent).Child(i)) {
+					o = append(o, Entry{
+						path:  path2.Path(parent).Child(i),
+						name:  i,
+						value: vvv,
+					})
+					continue
+				}
+				o = walk(path2.Path(parent).Child(i), vvv, o, leaf)
 			default:
 				o = append(o, Entry{
-					path:  append(parent, i),
+					path:  path2.Path(parent).Child(i),
 					name:  i,
 					value: vv[i],
 				})
@@ -73,6 +87,27 @@ func walk(p []string, v interface{}, in Entries) (o Entries) {
 var _ encoding.TextMarshaler = &Command{}
 
 func (c *Command) MarshalText() (text []byte, err error) {
+	all, _ := ParseTagQuery("")
+	return c.marshal(all, true)
+}
+
+// MarshalTags writes a configuration file with only the options whose tags
+// match a tag expression, see ParseTagQuery, such as the options of one role
+// of an application. Profiles are not included.
+func (c *Command) MarshalTags(expr string) (text []byte, err error) {
+	var q TagQuery
+	if q, err = ParseTagQuery(expr); err != nil {
+		return
+	}
+	return c.marshal(q, false)
+}
+
+// marshal writes the options selected by a query, and the profile sections if
+// profiles is set.
+func (c *Command) marshal(q TagQuery, profiles bool) (text []byte,
+	err error) {
+
+	text = append(text, c.schemaHeader()...)
 	c.ForEach(func(cmd *Command, depth int) bool {
 		if cmd == nil {
 			log.I.Ln("cmd empty")
@@ -80,7 +115,9 @@ func (c *Command) MarshalText() (text []byte, err error) {
 		}
 		cfgNames := make([]string, 0, len(cmd.Configs))
 		for i := range cmd.Configs {
-			cfgNames = append(cfgNames, i)
+			if q.Match(cmd.Configs[i].Meta().Tags()) {
+				cfgNames = append(cfgNames, i)
+			}
 		}
 		if len(cfgNames) < 1 {
 			return true
@@ -90,7 +127,7 @@ func (c *Command) MarshalText() (text []byte, err error) {
 			current := cmd.Parent
 			for current != nil {
 				if current.Name != "" {
-					cmdPath = current.Name + "."
+					cmdPath = current.Name + "." + cmdPath
 				}
 				current = current.Parent
 			}
@@ -102,132 +139,336 @@ func (c *Command) MarshalText() (text []byte, err error) {
 		sort.Strings(cfgNames)
 		for _, i := range cfgNames {
 			md := cmd.Configs[i].Meta()
-			lq, rq := "", ""
-			st := cmd.Configs[i].String()
-			df := md.Default()
-			switch cmd.Configs[i].Type() {
-			case meta.Duration, meta.Text:
-				lq, rq = "\"", "\""
-			case meta.List:
-				lq, rq = "[ \"", "\" ]"
-				st = strings.ReplaceAll(st, ",", "\", \"")
-				df = strings.ReplaceAll(df, ",", "\", \"")
-				if st == "" {
-					lq, rq = "[ ", "]"
-				}
+			st, ok, e := c.tomlSaved(cmd, i, c.savedValue(cmd.Configs[i]))
+			if !ok {
+				continue
+			}
+			var def string
+			if e == nil {
+				def, e = tomlValue(cmd.Configs[i], md.Default())
+			}
+			if e != nil {
+				err = fmt.Errorf("%s: %w", cmd.Path.Child(i), e)
+				return false
 			}
 			text = append(text,
 				[]byte("# "+i+" - "+md.Description()+
-					" - default: "+lq+df+rq+"\n")...)
-			text = append(text,
-				[]byte(i+" = "+lq+st+rq+"\n")...)
+					" - default: "+def+"\n")...)
+			text = append(text, []byte(i+" = "+st+"\n")...)
 		}
 		text = append(text, []byte("\n")...)
 		return true
 	}, 0, 0, c)
+	if err != nil {
+		return nil, err
+	}
+	if !profiles {
+		return
+	}
+	var sections []byte
+	if sections, err = c.marshalProfiles(); log.E.Chk(err) {
+		return
+	}
+	text = append(text, sections...)
 	return
 }
 
-var _ encoding.TextUnmarshaler = &Command{}
+// schemaHeader is the start of a configuration file, with its schema version.
+func (c *Command) schemaHeader() []byte {
+	return []byte(fmt.Sprintf(
+		"# schema version of this file, do not change\n%s = %d\n\n",
+		SchemaVersionKey, c.SchemaVersion()))
+}
 
-func (c *Command) UnmarshalText(t []byte) (err error) {
-	var out interface{}
-	err = toml.Unmarshal(t, &out)
-	oo := walk([]string{}, out, []Entry{})
-	sort.Sort(oo)
-	for i := range oo {
-		op := c.GetOpt(oo[i].path)
-		if op != nil {
-			switch op.Type() {
-			case meta.Bool:
-				v := op.(*toggle.Opt)
-				nv, ok := oo[i].value.(bool)
-				if ok {
-					v.FromValue(nv)
-				}
-				log.T.Ln("setting value of", oo[i].path, "to", nv)
-			case meta.Duration:
-				v := op.(*duration.Opt)
-				nv, ok := oo[i].value.(time.Duration)
-				if ok {
-					v.FromValue(nv)
-				}
-				log.T.Ln("setting value of", oo[i].path, "to", nv)
-			case meta.Float:
-				v := op.(*float.Opt)
-				nv, ok := oo[i].value.(float64)
-				if ok {
-					v.FromValue(nv)
-				}
-				log.T.Ln("setting value of", oo[i].path, "to", nv)
-			case meta.Integer:
-				v := op.(*integer.Opt)
-				nv, ok := oo[i].value.(int64)
-				if ok {
-					v.FromValue(nv)
-				}
-				log.T.Ln("setting value of", oo[i].path, "to", nv)
-			case meta.List:
-				v := op.(*list.Opt)
-				nv, ok := oo[i].value.([]interface{})
-				var strings []string
-				for i := range nv {
-					strings = append(strings, nv[i].(string))
-				}
-				if ok {
-					v.FromValue(strings)
-				}
-				log.T.Ln("setting value of", oo[i].path, "to", nv)
-			case meta.Text:
-				v := op.(*text.Opt)
-				nv, ok := oo[i].value.(string)
-				if ok {
-					v.FromValue(nv)
-				}
-				log.T.Ln("setting value of", oo[i].path, "to", nv)
-			default:
-				log.E.Ln("option type unknown:", oo[i].path, op.Type())
+// tomlSaved formats the value of an option of a Command as it is saved in
+// the configuration file, which for a secret saved as a reference is the
+// reference to its environment variable. Secrets that are not saved are not
+// ok.
+func (c *Command) tomlSaved(cmd *Command, name, value string) (v string,
+	ok bool, err error) {
+
+	op := cmd.Configs[name]
+	if sec, isSecret := op.(*secret.Opt); isSecret {
+		switch {
+		case sec.SaveMode() == secret.SaveReference:
+			value = secret.EnvPrefix + c.EnvVar(cmd.Path.Child(name))
+		case !sec.SavesValue(value):
+			return
+		}
+	}
+	v, err = tomlValue(op, value)
+	return v, true, err
+}
+
+// tomlValue formats a value of an option as TOML, quoting it, or writing it
+// as an array or inline table, as the type of the option needs. It is an
+// error if a list or map does not parse.
+func tomlValue(op config.Option, s string) (v string, err error) {
+	quote := false
+	switch op.Type() {
+	case meta.Duration, meta.IP, meta.Size, meta.Text, meta.URL,
+		meta.Secret:
+		quote = true
+	case meta.Integer:
+		quote = op.(*integer.Opt).HasUnits()
+	case meta.Float:
+		quote = op.(*float.Opt).HasUnits()
+	case meta.CIDR, meta.Endpoint, meta.Enum:
+		if !multi(op) {
+			quote = true
+			break
+		}
+		if s == "" {
+			return "[]", nil
+		}
+		items := strings.Split(s, ",")
+		for i := range items {
+			items[i] = tomlString(items[i])
+		}
+		return "[ " + strings.Join(items, ", ") + " ]", nil
+	case meta.Map:
+		o := op.(*mapopt.Opt)
+		var m map[string]string
+		if m, err = o.Parse(s); err != nil {
+			return
+		}
+		return inlineTable(o, m), nil
+	case meta.List:
+		var items []string
+		if items, err = op.(*list.Opt).Parse(s); err != nil {
+			return
+		}
+		return tomlArray(items), nil
+	}
+	if quote {
+		return tomlString(s), nil
+	}
+	return s, nil
+}
+
+// tomlString quotes a string as a TOML basic string, escaping quotes,
+// backslashes and control characters.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
 			}
-		} else {
-			log.D.Ln("option not found:", oo[i].path)
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// multi returns true if an option can hold several values, which are
+// written as an array.
+func multi(op config.Option) bool {
+	switch o := op.(type) {
+	case *cidr.Opt:
+		return o.IsMulti()
+	case *endpoint.Opt:
+		return o.IsMulti()
+	case *enum.Opt:
+		return o.IsMulti()
+	}
+	return false
+}
+
+// inlineTable formats the entries of a map as a TOML inline table, quoting
+// the values unless they are numbers or booleans.
+func inlineTable(o *mapopt.Opt, v map[string]string) string {
+	if len(v) < 1 {
+		return "{}"
+	}
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]string, len(keys))
+	for i, k := range keys {
+		key, value := k, v[k]
+		if !bareKey(key) {
+			key = tomlString(key)
+		}
+		switch o.ValueType() {
+		case meta.Bool, meta.Float, meta.Integer:
+		default:
+			value = tomlString(value)
+		}
+		items[i] = key + " = " + value
+	}
+	return "{ " + strings.Join(items, ", ") + " }"
+}
+
+// tomlArray formats the items of a list as a TOML array of strings.
+func tomlArray(v []string) string {
+	if len(v) < 1 {
+		return "[]"
+	}
+	items := make([]string, len(v))
+	for i := range v {
+		items[i] = tomlString(v[i])
+	}
+	return "[ " + strings.Join(items, ", ") + " ]"
+}
+
+// bareKey returns true if a TOML key does not need quotes.
+func bareKey(k string) bool {
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
 		}
 	}
-	return nil
+	return k != ""
+}
+
+var _ encoding.TextUnmarshaler = &Command{}
+
+// UnmarshalText parses a configuration file, migrates it to the current
+// schema version and applies its values.
+//
+// Every problem found is reported as a Diagnostic. In strict mode these are
+// returned as an error and no values are applied, otherwise they are logged
+// as warnings and the valid values are applied.
+func (c *Command) UnmarshalText(t []byte) (err error) {
+	_, _, err = c.decode("", t, false)
+	return
 }
+
+// ValidateConfig checks the configuration file without applying it.
+func (c *Command) ValidateConfig() (diags Diagnostics, err error) {
+	var all []byte
+	if all, err = c.readConfig(); err != nil {
+		return
+	}
+	_, diags, err = c.decode(c.configFile(), all, true)
+	return
+}
+
+// decode parses and migrates a configuration file and checks it against the
+// options, along with the profiles. Unless dryRun is set, the values are then
+// applied, followed by those of the selected profiles in order. It returns the
+// schema version the file was written with.
+func (c *Command) decode(file string, t []byte, dryRun bool) (from int,
+	diags Diagnostics, err error) {
+
+	var d decoded
+	if d, diags, err = c.parseConfig(file, t); err != nil || dryRun {
+		return d.from, diags, err
+	}
+	for i := range diags {
+		log.W.Ln(diags[i])
+	}
+	for i := range d.set {
+		d.set[i].apply()
+	}
+	c.profileDoc = d.profiles
+	c.profiled = make(map[config.Option]profileValue)
+	c.applyProfiles(d.pset)
+	return d.from, diags, nil
+}
+
+// decoded is a configuration file checked against the options, with the
+// settings that apply it.
+type decoded struct {
+	from     int                    // the schema version it was written with
+	set      []setting              // the values of the file
+	profiles map[string]interface{} // the profile sections
+	pset     []setting              // the values of the selected profiles
+}
+
+// parseConfig parses and migrates a configuration file and checks it and its
+// profiles against the options, without changing them. Problems found are
+// only an error if the configuration is strict.
+func (c *Command) parseConfig(file string, t []byte) (d decoded,
+	diags Diagnostics, err error) {
+
+	var tbl *ast.Table
+	if tbl, err = toml.Parse(t); err != nil {
+		diags = Diagnostics{parseDiagnostic(file, err)}
+		return d, diags, diags
+	}
+	doc := Document{}
+	if err = toml.UnmarshalTable(tbl,
+		(*map[string]interface{})(&doc)); err != nil {
+
+		diags = Diagnostics{parseDiagnostic(file, err)}
+		return d, diags, diags
+	}
+	if d.from, err = c.Migrate(doc); err != nil {
+		return
+	}
+	pos := getPositions(t, tbl)
+	var pd, cd, prd Diagnostics
+	d.profiles, pd = doc.takeProfiles()
+	d.set, cd = c.check(file, doc, pos)
+	d.pset, prd = c.checkProfiles(file, d.profiles, pos, c.Profiles())
+	diags = append(append(pd, cd...), prd...)
+	if len(diags) > 0 && c.strict() {
+		err = diags
+	}
+	return
+}
+
+// LoadConfig reads the configuration file, decrypting it if it is encrypted,
+// and creates it with the current values if it does not exist. A file with an
+// older schema version is migrated, and rewritten after keeping a backup.
 func (c *Command) LoadConfig() (err error) {
-	cfgFile := c.GetOpt(path2.Path{c.Name, "ConfigFile"})
-	var file io.Reader
-	if file, err = os.Open(cfgFile.Expanded()); err != nil {
-		log.T.F("creating config file at path: '%s'", cfgFile.Expanded())
+	var all []byte
+	if all, err = c.readConfig(); err != nil {
+		if !os.IsNotExist(err) {
+			log.E.Chk(err)
+			return
+		}
+		log.T.F("creating config file at path: '%s'", c.configFile())
 		// If no config found, create data dir and drop the default in place
 		return c.SaveConfig()
-	} else {
-		var all []byte
-		all, err = io.ReadAll(file)
-		err = c.UnmarshalText(all)
-		if log.E.Chk(err) {
+	}
+	var from int
+	if from, _, err = c.decode(c.configFile(), all, false); log.E.Chk(err) {
+		return
+	}
+	if from < c.SchemaVersion() {
+		// keep the file as it was and replace it with the migrated version
+		if err = c.backupConfig(from); log.E.Chk(err) {
 			return
 		}
+		err = c.SaveConfig()
 	}
 	return
 }
 
+// SaveConfig writes the configuration file, encrypted if the file it
+// replaces was encrypted.
 func (c *Command) SaveConfig() (err error) {
 	datadir := c.GetOpt(path2.Path{c.Name, "DataDir"})
 	if err = os.MkdirAll(datadir.Expanded(), 0700); log.E.Chk(err) {
 		return err
 	}
-	var f *os.File
-	cfgFile := c.GetOpt(path2.From(c.Name + " configfile"))
-	f, err = os.OpenFile(cfgFile.Expanded(), os.O_RDWR|os.O_CREATE, 0666)
-	if log.E.Chk(err) {
-		return
-	}
 	var cf []byte
 	if cf, err = c.MarshalText(); log.E.Chk(err) {
 		return
 	}
-	_, err = f.Write(cf)
-	log.E.Chk(err)
+	err = c.writeConfig(cf)
 	return
 }
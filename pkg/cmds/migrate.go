@@ -0,0 +1,213 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
+)
+
+// SchemaVersionKey is the top level key in the configuration file that holds
+// the schema version the file was written with.
+const SchemaVersionKey = "SchemaVersion"
+
+// Document is a parsed configuration file. Tables are nested
+// map[string]interface{} and the option values are the types produced by the
+// TOML decoder. Paths are addressed in the same way as options, starting with
+// the name of the root Command.
+type Document map[string]interface{}
+
+// Migration rewrites a Document from the previous schema version to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(d Document) error
+}
+
+// Migrations are applied in ascending order of Version.
+type Migrations []Migration
+
+func (m Migrations) Len() int           { return len(m) }
+func (m Migrations) Less(i, j int) bool { return m[i].Version < m[j].Version }
+func (m Migrations) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// AddMigration registers a Migration on the Command, which should be the root.
+func (c *Command) AddMigration(m Migration) {
+	c.Migrations = append(c.Migrations, m)
+}
+
+// SchemaVersion returns the current schema version, the highest Version of
+// the registered Migrations.
+func (c *Command) SchemaVersion() (v int) {
+	for i := range c.Migrations {
+		if c.Migrations[i].Version > v {
+			v = c.Migrations[i].Version
+		}
+	}
+	return
+}
+
+// Version returns the schema version recorded in the Document, which is zero
+// for files written before versioning.
+func (d Document) Version() int {
+	if v, ok := d[SchemaVersionKey].(int64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// Migrate runs the Migrations newer than the version of the Document in
+// order, and records the new version in it. The previous version is returned.
+func (c *Command) Migrate(d Document) (from int, err error) {
+	from = d.Version()
+	to := c.SchemaVersion()
+	if from > to {
+		return from, fmt.Errorf("configuration schema version %d is newer "+
+			"than the supported version %d", from, to)
+	}
+	m := make(Migrations, len(c.Migrations))
+	copy(m, c.Migrations)
+	sort.Stable(m)
+	for i := range m {
+		if m[i].Version <= from {
+			continue
+		}
+		log.I.F("migrating configuration to schema version %d: %s",
+			m[i].Version, m[i].Description)
+		if err = m[i].Migrate(d); err != nil {
+			return from, fmt.Errorf("migration to schema version %d: %w",
+				m[i].Version, err)
+		}
+	}
+	d[SchemaVersionKey] = int64(to)
+	return
+}
+
+// backupConfig copies the configuration file as it is on disk to a file
+// named for the schema version it was written with.
+func (c *Command) backupConfig(version int) (err error) {
+	var data []byte
+	if data, err = os.ReadFile(c.configFile()); log.E.Chk(err) {
+		return
+	}
+	name := fmt.Sprintf("%s.v%d.bak", c.configFile(), version)
+	if err = os.WriteFile(name, data, 0600); log.E.Chk(err) {
+		return
+	}
+	log.I.Ln("previous configuration saved as", name)
+	return
+}
+
+// table returns the table holding the last element of the path, creating the
+// missing tables if create is set.
+func (d Document) table(p path.Path, create bool) (t map[string]interface{},
+	key string, ok bool) {
+
+	if len(p) < 1 {
+		return
+	}
+	t = d
+	for i := range p[:len(p)-1] {
+		k, found := d.key(t, p[i])
+		if !found {
+			if !create {
+				return nil, "", false
+			}
+			t[k] = map[string]interface{}{}
+		}
+		var next map[string]interface{}
+		if next, ok = t[k].(map[string]interface{}); !ok {
+			return nil, "", false
+		}
+		t = next
+	}
+	key, _ = d.key(t, p[len(p)-1])
+	return t, key, true
+}
+
+// key finds the key in a table that matches name ignoring case, or returns
+// name if there is none.
+func (d Document) key(t map[string]interface{}, name string) (k string,
+	found bool) {
+
+	for i := range t {
+		if util.Norm(i) == util.Norm(name) {
+			return i, true
+		}
+	}
+	return name, false
+}
+
+// Get returns the value at a path.
+func (d Document) Get(p path.Path) (v interface{}, ok bool) {
+	t, k, found := d.table(p, false)
+	if !found {
+		return
+	}
+	v, ok = t[k]
+	return
+}
+
+// Set puts a value at a path, creating tables as needed.
+func (d Document) Set(p path.Path, v interface{}) (err error) {
+	t, k, found := d.table(p, true)
+	if !found {
+		return fmt.Errorf("cannot set %s, a parent is not a table", p)
+	}
+	t[k] = v
+	return
+}
+
+// Delete removes the value at a path, if it exists.
+func (d Document) Delete(p path.Path) {
+	if t, k, found := d.table(p, false); found {
+		delete(t, k)
+	}
+}
+
+// Rename moves a value to a new path, which can be in a different table. It
+// does nothing if there is no value at the old path.
+func (d Document) Rename(from, to path.Path) (err error) {
+	v, ok := d.Get(from)
+	if !ok {
+		return
+	}
+	d.Delete(from)
+	return d.Set(to, v)
+}
+
+// Convert replaces the value at a path with the result of a function, if
+// there is a value.
+func (d Document) Convert(p path.Path,
+	fn func(v interface{}) (interface{}, error)) (err error) {
+
+	v, ok := d.Get(p)
+	if !ok {
+		return
+	}
+	if v, err = fn(v); err != nil {
+		return fmt.Errorf("converting %s: %w", p, err)
+	}
+	return d.Set(p, v)
+}
+
+// SplitList converts a string value into a list by splitting it with a
+// separator.
+func (d Document) SplitList(p path.Path, sep string) (err error) {
+	return d.Convert(p, func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("value is %T not a string", v)
+		}
+		var l []interface{}
+		for _, item := range strings.Split(s, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				l = append(l, item)
+			}
+		}
+		return l, nil
+	})
+}
@@ -0,0 +1,206 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/secret"
+	path2 "github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
+	"github.com/naoina/toml"
+	"github.com/naoina/toml/ast"
+)
+
+// ProfileKey is the top level table of the configuration file that holds the
+// named profiles, such as [profile.testnet.node].
+const ProfileKey = "profile"
+
+// profilesDir is the folder in the data directory holding profile files named
+// <name>.toml.
+const profilesDir = "profiles"
+
+// profileValue is the value of an option before a profile changed it, and the
+// value the profile gave it.
+type profileValue struct {
+	base, overlay string
+}
+
+// Profiles returns the names of the profiles to apply, in order, from the
+// Profile option, or if it is empty, from its environment variables. The
+// environment is not yet loaded when the configuration is read so it is
+// looked up here, with the EnvLookup given to SetEnvLookup.
+func (c *Command) Profiles() (names []string) {
+	op := c.GetOpt(path2.Path{c.Name, "Profile"})
+	if op == nil {
+		return
+	}
+	items := op.Value().List()
+	if len(strings.Join(items, "")) < 1 {
+		p := path2.Path{c.Name, "Profile"}
+		env := Env{Name: p, Var: c.EnvVar(p), Aliases: op.Meta().EnvAliases()}
+		for _, name := range env.Vars() {
+			if v, _, exists := c.lookupEnv(name); exists {
+				if o, ok := op.(*list.Opt); ok {
+					items, _ = o.Parse(v)
+				} else {
+					items = strings.Split(v, ",")
+				}
+				break
+			}
+		}
+	}
+	for i := range items {
+		if s := strings.TrimSpace(items[i]); s != "" {
+			names = append(names, s)
+		}
+	}
+	return
+}
+
+// profileFile returns the path of the file for a profile.
+func (c *Command) profileFile(name string) string {
+	dd := c.GetOpt(path2.Path{c.Name, "DataDir"})
+	return filepath.Join(dd.Expanded(), profilesDir, name+".toml")
+}
+
+// takeProfiles removes the profile sections from a Document and returns them.
+func (d Document) takeProfiles() (profiles map[string]interface{},
+	diags Diagnostics) {
+
+	k, found := d.key(d, ProfileKey)
+	if !found {
+		return
+	}
+	var ok bool
+	if profiles, ok = d[k].(map[string]interface{}); !ok {
+		diags = Diagnostics{{Path: path2.Path{k},
+			Message: fmt.Sprintf("%s must be a table", k)}}
+	}
+	delete(d, k)
+	return
+}
+
+// rebase moves the positions under one path to another.
+func (p positions) rebase(from, to path2.Path) (o positions) {
+	o = positions{}
+	f, t := util.Norm(from.String()), util.Norm(to.String())
+	for k, v := range p {
+		if k == f {
+			o[t] = v
+		} else if strings.HasPrefix(k, f+" ") {
+			o[t+k[len(f):]] = v
+		}
+	}
+	return
+}
+
+// checkProfiles checks every profile section in the configuration file, and
+// returns the settings of the selected profiles in the order they are
+// applied. The profile file of each selected profile is applied after its
+// section.
+func (c *Command) checkProfiles(file string, profiles map[string]interface{},
+	pos positions, names []string) (set []setting, diags Diagnostics) {
+
+	sections := make(map[string][]setting)
+	var all []string
+	for name := range profiles {
+		all = append(all, name)
+	}
+	sort.Strings(all)
+	for _, name := range all {
+		section, ok := profiles[name].(map[string]interface{})
+		if !ok {
+			diags = append(diags, Diagnostic{File: file,
+				Path:    path2.Path{ProfileKey, name},
+				Message: fmt.Sprintf("profile %s must be a table", name)})
+			continue
+		}
+		s, d := c.check(file, Document{c.Name: section},
+			pos.rebase(path2.Path{ProfileKey, name}, path2.Path{c.Name}))
+		sections[util.Norm(name)] = s
+		diags = append(diags, d...)
+	}
+	for _, name := range names {
+		s, found := sections[util.Norm(name)]
+		set = append(set, s...)
+		pf := c.profileFile(name)
+		data, err := os.ReadFile(pf)
+		switch {
+		case err == nil:
+			found = true
+			var tbl *ast.Table
+			if tbl, err = toml.Parse(data); err != nil {
+				diags = append(diags, parseDiagnostic(pf, err))
+				continue
+			}
+			doc := Document{}
+			if err = toml.UnmarshalTable(tbl,
+				(*map[string]interface{})(&doc)); err != nil {
+
+				diags = append(diags, parseDiagnostic(pf, err))
+				continue
+			}
+			s, d := c.check(pf, doc, getPositions(data, tbl))
+			set = append(set, s...)
+			diags = append(diags, d...)
+		case !os.IsNotExist(err):
+			diags = append(diags, Diagnostic{File: pf, Message: err.Error()})
+		}
+		if !found {
+			diags = append(diags, Diagnostic{File: file,
+				Message: fmt.Sprintf("profile %s not found in the "+
+					"configuration file or %s", name, pf)})
+		}
+	}
+	return
+}
+
+// applyProfiles stores the settings of the profiles, recording the values
+// they replace so that saving the configuration does not write the profile
+// values into the base configuration.
+func (c *Command) applyProfiles(set []setting) {
+	for i := range set {
+		op := set[i].op
+		before := storedValue(op)
+		set[i].apply()
+		pv, ok := c.profiled[op]
+		if !ok {
+			pv.base = before
+		}
+		pv.overlay = storedValue(op)
+		c.profiled[op] = pv
+	}
+}
+
+// storedValue is the value of an option as written to the configuration file.
+func storedValue(op config.Option) string {
+	if sec, ok := op.(*secret.Opt); ok {
+		return sec.Raw()
+	}
+	return op.String()
+}
+
+// savedValue returns the value of an option to write to the configuration
+// file, which is the base value if a profile set it and it has not been
+// changed since.
+func (c *Command) savedValue(op config.Option) string {
+	v := storedValue(op)
+	if pv, ok := c.profiled[op]; ok && pv.overlay == v {
+		return pv.base
+	}
+	return v
+}
+
+// marshalProfiles encodes the profile sections read from the configuration
+// file so they are kept when it is saved.
+func (c *Command) marshalProfiles() (text []byte, err error) {
+	if len(c.profileDoc) < 1 {
+		return
+	}
+	return toml.Marshal(map[string]interface{}{ProfileKey: c.profileDoc})
+}
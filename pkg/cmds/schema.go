@@ -0,0 +1,225 @@
+package cmds
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	integer "github.com/cybriq/proc/pkg/opts/Integer"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/float"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/mapopt"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/opts/urlopt"
+)
+
+// JSONSchemaDraft is the JSON Schema version of the output of JSONSchema.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns a JSON Schema describing the configuration file of the
+// Command tree, which must be the root. It can be used by editors to check
+// and complete configuration files.
+func (c *Command) JSONSchema() (schema []byte, err error) {
+	s := map[string]interface{}{
+		"$schema":     JSONSchemaDraft,
+		"title":       c.Name,
+		"description": c.Description,
+		"type":        "object",
+		"properties": map[string]interface{}{
+			SchemaVersionKey: map[string]interface{}{
+				"type":        "integer",
+				"description": "schema version of the file",
+				"const":       c.SchemaVersion(),
+			},
+			c.Name: commandSchema(c),
+			ProfileKey: map[string]interface{}{
+				"type":        "object",
+				"description": "named profiles that overlay the configuration",
+				"additionalProperties": map[string]interface{}{
+					"type": "object",
+				},
+			},
+		},
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// commandSchema describes the table of a Command.
+func commandSchema(c *Command) map[string]interface{} {
+	props := make(map[string]interface{})
+	for name := range c.Configs {
+		props[name] = OptionSchema(c.Configs[name])
+	}
+	for i := range c.Commands {
+		if len(c.Commands[i].Configs) > 0 || len(c.Commands[i].Commands) > 0 {
+			props[c.Commands[i].Name] = commandSchema(c.Commands[i])
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"description":          c.Description,
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+// OptionSchema returns the JSON Schema of the value of an option.
+func OptionSchema(op config.Option) (s map[string]interface{}) {
+	s = typeSchema(op)
+	validatorSchema(s, op.Meta())
+	return
+}
+
+// typeSchema returns the JSON Schema of the type of an option.
+func typeSchema(op config.Option) (s map[string]interface{}) {
+	md := op.Meta()
+	s = map[string]interface{}{}
+	if md.Description() != "" {
+		s["description"] = md.Description()
+	}
+	if md.Label() != "" {
+		s["title"] = md.Label()
+	}
+	choices := md.Options()
+	df := md.Default()
+	switch op.Type() {
+	case meta.Bool:
+		s["type"] = "boolean"
+		if v, e := strconv.ParseBool(df); e == nil {
+			s["default"] = v
+		}
+	case meta.Integer:
+		s["type"] = "integer"
+		if v, e := strconv.ParseInt(df, 10, 64); e == nil {
+			s["default"] = v
+		}
+		if op.(*integer.Opt).HasUnits() {
+			s["type"] = []string{"integer", "string"}
+			s["default"] = df
+		}
+	case meta.Float:
+		s["type"] = "number"
+		if v, e := strconv.ParseFloat(df, 64); e == nil {
+			s["default"] = v
+		}
+		if op.(*float.Opt).HasUnits() {
+			s["type"] = []string{"number", "string"}
+			s["default"] = df
+		}
+	case meta.Size:
+		s["type"] = []string{"integer", "string"}
+		s["default"] = df
+	case meta.Duration:
+		s["type"] = "string"
+		if _, e := time.ParseDuration(df); e == nil {
+			s["default"] = df
+		}
+	case meta.List:
+		s["type"] = "array"
+		s["items"] = map[string]interface{}{"type": "string"}
+		if v, e := op.(*list.Opt).Parse(df); e == nil {
+			s["default"] = v
+		}
+	case meta.CIDR, meta.Endpoint:
+		if multi(op) {
+			s["type"] = "array"
+			s["items"] = map[string]interface{}{"type": "string"}
+			s["default"] = splitDefault(df)
+			return
+		}
+		s["type"] = "string"
+		s["default"] = df
+	case meta.IP:
+		s["type"] = "string"
+		s["anyOf"] = []interface{}{
+			map[string]interface{}{"format": "ipv4"},
+			map[string]interface{}{"format": "ipv6"},
+			map[string]interface{}{"const": ""},
+		}
+		s["default"] = df
+	case meta.URL:
+		s["type"] = "string"
+		s["format"] = "uri"
+		if schemes := op.(*urlopt.Opt).AllowedSchemes(); len(schemes) > 0 {
+			s["pattern"] = "^(?i:" + strings.Join(schemes, "|") + "):"
+		}
+		if df != "" {
+			s["default"] = df
+		}
+	case meta.Enum:
+		if multi(op) {
+			s["type"] = "array"
+			s["items"] = map[string]interface{}{"enum": choices}
+			s["uniqueItems"] = true
+			s["default"] = splitDefault(df)
+			return
+		}
+		s["type"] = "string"
+		s["enum"] = choices
+		s["default"] = df
+		return
+	case meta.Map:
+		values := map[string]interface{}{"type": "string"}
+		switch op.(*mapopt.Opt).ValueType() {
+		case meta.Bool:
+			values["type"] = "boolean"
+		case meta.Float:
+			values["type"] = "number"
+		case meta.Integer:
+			values["type"] = "integer"
+		}
+		s["type"] = "object"
+		s["additionalProperties"] = values
+	case meta.Secret:
+		s["type"] = "string"
+		s["writeOnly"] = true
+	default:
+		s["type"] = "string"
+		s["default"] = df
+	}
+	if len(choices) > 0 {
+		s["enum"] = choices
+	}
+	return
+}
+
+// validatorSchema adds the JSON Schema keywords of the validators of an
+// option, and their descriptions as x-validators. The keywords for single
+// values apply to the items of arrays.
+func validatorSchema(s map[string]interface{}, md meta.Metadata) {
+	descriptions := md.Describe()
+	if len(descriptions) < 1 {
+		return
+	}
+	s["x-validators"] = descriptions
+	target := s
+	if items, ok := s["items"].(map[string]interface{}); ok {
+		target = items
+	}
+	for _, v := range md.Validators() {
+		for k, kw := range v.Schema {
+			switch k {
+			case "minItems", "maxItems":
+				if s["type"] == "array" {
+					s[k] = kw
+				}
+			case "minLength", "maxLength":
+				if s["type"] == "string" {
+					s[k] = kw
+				}
+			default:
+				target[k] = kw
+			}
+		}
+	}
+}
+
+// splitDefault turns the default of a list into its items.
+func splitDefault(df string) []string {
+	if strings.TrimSpace(df) == "" {
+		return []string{}
+	}
+	return strings.Split(df, ",")
+}
@@ -0,0 +1,259 @@
+package cmds
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/secret"
+	"github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
+)
+
+// Snapshot is the values of all the options of a Command tree at one time.
+// It cannot be changed, and can be compared with another with Diff and put
+// back with Restore.
+type Snapshot struct {
+	values map[string]snapshotValue // by normalised path
+}
+
+// snapshotValue is the value of one option in a Snapshot.
+type snapshotValue struct {
+	path   path.Path
+	stored string // as written to the configuration file, for Restore
+	shown  string // as the option prints it, with secrets redacted
+}
+
+// Snapshot captures the values of all the options of the tree the Command is
+// in.
+func (c *Command) Snapshot() (s *Snapshot) {
+	s = &Snapshot{values: make(map[string]snapshotValue)}
+	r := c.root()
+	r.ForEach(func(cmd *Command, _ int) bool {
+		for name, op := range cmd.Configs {
+			p := cmd.Path.Child(name)
+			s.values[util.Norm(p.String())] = snapshotValue{
+				path:   p,
+				stored: storedValue(op),
+				shown:  op.String(),
+			}
+		}
+		return true
+	}, 0, 0, r)
+	return
+}
+
+// Paths returns the paths of the options in the Snapshot, sorted.
+func (s *Snapshot) Paths() (paths []path.Path) {
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		paths = append(paths, append(path.Path{}, s.values[k].path...))
+	}
+	return
+}
+
+// Get returns the value of the option at a path as it printed when the
+// Snapshot was taken, so secrets are redacted.
+func (s *Snapshot) Get(p path.Path) (value string, ok bool) {
+	v, ok := s.values[util.Norm(p.String())]
+	return v.shown, ok
+}
+
+// DiffKind is how an option differs between two snapshots.
+type DiffKind int
+
+const (
+	// Changed is an option with a different value.
+	Changed DiffKind = iota
+	// Added is an option only in the newer Snapshot.
+	Added
+	// Removed is an option only in the older Snapshot.
+	Removed
+)
+
+// Difference is an option that differs between two snapshots, with its
+// values as the options print them, so secrets are redacted.
+type Difference struct {
+	Kind     DiffKind
+	Path     path.Path
+	Old, New string
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s = %s", d.Path, d.New)
+	case Removed:
+		return fmt.Sprintf("- %s = %s", d.Path, d.Old)
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", d.Path, d.Old, d.New)
+}
+
+// Diff is the differences between two snapshots, sorted by path.
+type Diff []Difference
+
+func (d Diff) String() string {
+	s := make([]string, len(d))
+	for i := range d {
+		s[i] = d[i].String()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Diff returns the differences from the Snapshot to a newer one. Secrets are
+// compared by their values, and shown redacted.
+func (s *Snapshot) Diff(to *Snapshot) (d Diff) {
+	for k, old := range s.values {
+		v, ok := to.values[k]
+		switch {
+		case !ok:
+			d = append(d, Difference{Removed, old.path, old.shown, ""})
+		case v.stored != old.stored:
+			d = append(d, Difference{Changed, v.path, old.shown, v.shown})
+		}
+	}
+	for k, v := range to.values {
+		if _, ok := s.values[k]; !ok {
+			d = append(d, Difference{Added, v.path, "", v.shown})
+		}
+	}
+	sort.Slice(d, func(i, j int) bool {
+		return util.Norm(d[i].Path.String()) < util.Norm(d[j].Path.String())
+	})
+	return
+}
+
+// Restore sets the options of the tree back to the values of a Snapshot as
+// one Update, so if any of them fails none are changed. Options the Snapshot
+// does not have are not changed, and an option of the Snapshot that is not in
+// the tree is an error.
+func (c *Command) Restore(s *Snapshot) (err error) {
+	r := c.root()
+	return r.Update(func(tx *Tx) (err error) {
+		for _, p := range s.Paths() {
+			v := s.values[util.Norm(p.String())]
+			if op := r.GetOpt(p); op != nil && storedValue(op) == v.stored {
+				continue
+			}
+			if err = tx.Set(p, v.stored); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// snapshotFile returns a Snapshot of the values the tree would have with only
+// a configuration file applied, or with no file, the defaults. The file is
+// evaluated apart from the options, which are not changed and run no hooks.
+func (c *Command) snapshotFile(file string) (s *Snapshot, err error) {
+	r := c.root()
+	values := make(map[config.Option]string)
+	if file != "" {
+		var data []byte
+		if data, err = r.readConfigFile(file); err != nil {
+			return
+		}
+		var d decoded
+		var diags Diagnostics
+		if d, diags, err = r.parseConfig(file, data); err != nil {
+			return
+		}
+		for i := range diags {
+			log.W.Ln(diags[i])
+		}
+		for _, set := range append(d.set, d.pset...) {
+			values[set.op] = fileString(set.op, set.value)
+		}
+	}
+	s = &Snapshot{values: make(map[string]snapshotValue)}
+	r.ForEach(func(cmd *Command, _ int) bool {
+		for name, op := range cmd.Configs {
+			v := snapshotValue{path: cmd.Path.Child(name)}
+			if v.stored, err = normalised(op, values); err != nil {
+				err = fmt.Errorf("%s: %w", v.path, err)
+				return false
+			}
+			v.shown = v.stored
+			if _, ok := op.(*secret.Opt); ok && v.shown != "" {
+				v.shown = secret.Redacted
+			}
+			s.values[util.Norm(v.path.String())] = v
+		}
+		return true
+	}, 0, 0, r)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// normalised returns the value an option would store for its value from a
+// configuration file, or if it has none, for its default, without storing it.
+func normalised(op config.Option, values map[config.Option]string) (string,
+	error) {
+
+	n, ok := op.(opts.Normaliser)
+	v, set := values[op]
+	switch {
+	case !ok && set:
+		return v, nil
+	case !ok:
+		return op.Meta().Default(), nil
+	case set:
+		return n.Normalise(v)
+	}
+	return n.Initial(), nil
+}
+
+// fileString writes a value decoded from a configuration file in the form
+// FromString reads.
+func fileString(op config.Option, value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i := range v {
+			items[i] = fmt.Sprint(v[i])
+		}
+		if o, ok := op.(*list.Opt); ok {
+			return list.Join(items, o.Sep())
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		items := make([]string, 0, len(v))
+		for k := range v {
+			items = append(items, k+"="+fmt.Sprint(v[k]))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+func configDiff(c *Command, args []string) (err error) {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("diff needs one or two configuration files")
+	}
+	var from, to *Snapshot
+	if len(args) == 1 {
+		if from, err = c.snapshotFile(""); err != nil {
+			return
+		}
+		to, err = c.snapshotFile(args[0])
+	} else if from, err = c.snapshotFile(args[0]); err == nil {
+		to, err = c.snapshotFile(args[1])
+	}
+	if err != nil {
+		return
+	}
+	if d := from.Diff(to); len(d) > 0 {
+		fmt.Println(d)
+	}
+	return
+}
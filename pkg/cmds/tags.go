@@ -0,0 +1,239 @@
+package cmds
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
+)
+
+// TagQuery selects options by the tags in their metadata.
+type TagQuery func(tags map[string]bool) bool
+
+// ParseTagQuery reads a tag expression, such as "node && !tls". Tags are
+// combined with && (and), || (or) and ! (not), with parentheses for grouping,
+// and ! binding tightest, then &&. Tags are matched ignoring case. An empty
+// expression selects every option.
+func ParseTagQuery(expr string) (q TagQuery, err error) {
+	p := &tagParser{src: expr}
+	p.next()
+	if p.tok == "" {
+		return func(map[string]bool) bool { return true }, nil
+	}
+	if q, err = p.or(); err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, p.fail("unexpected '%s'", p.tok)
+	}
+	return
+}
+
+// Match returns true if the tags are selected by the query.
+func (q TagQuery) Match(tags []string) bool {
+	set := make(map[string]bool, len(tags))
+	for i := range tags {
+		set[util.Norm(tags[i])] = true
+	}
+	return q(set)
+}
+
+// tagParser is a recursive descent parser of tag expressions.
+type tagParser struct {
+	src string
+	pos int    // position after the current token
+	at  int    // position of the current token
+	tok string // current token, empty at the end
+}
+
+func (p *tagParser) fail(format string, a ...interface{}) error {
+	return fmt.Errorf("tag expression '%s' at %d: %s", p.src, p.at+1,
+		fmt.Sprintf(format, a...))
+}
+
+// next reads the next token, which is an operator, a parenthesis or a tag.
+func (p *tagParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	p.at = p.pos
+	switch {
+	case p.pos >= len(p.src):
+		p.tok = ""
+		return
+	case strings.HasPrefix(p.src[p.pos:], "&&"),
+		strings.HasPrefix(p.src[p.pos:], "||"):
+		p.pos += 2
+	case strings.ContainsRune("!()", rune(p.src[p.pos])):
+		p.pos++
+	default:
+		for p.pos < len(p.src) {
+			r, n := utf8.DecodeRuneInString(p.src[p.pos:])
+			if !isTagChar(r) {
+				break
+			}
+			p.pos += n
+		}
+		if p.pos == p.at {
+			// a character that cannot be in a tag is a token of its own
+			_, n := utf8.DecodeRuneInString(p.src[p.pos:])
+			p.pos += n
+		}
+	}
+	p.tok = p.src[p.at:p.pos]
+}
+
+func isTagChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(
+		"-_.:/", r)
+}
+
+func (p *tagParser) or() (q TagQuery, err error) {
+	if q, err = p.and(); err != nil {
+		return
+	}
+	for p.tok == "||" {
+		p.next()
+		var r TagQuery
+		if r, err = p.and(); err != nil {
+			return
+		}
+		l := q
+		q = func(t map[string]bool) bool { return l(t) || r(t) }
+	}
+	return
+}
+
+func (p *tagParser) and() (q TagQuery, err error) {
+	if q, err = p.not(); err != nil {
+		return
+	}
+	for p.tok == "&&" {
+		p.next()
+		var r TagQuery
+		if r, err = p.not(); err != nil {
+			return
+		}
+		l := q
+		q = func(t map[string]bool) bool { return l(t) && r(t) }
+	}
+	return
+}
+
+func (p *tagParser) not() (q TagQuery, err error) {
+	switch p.tok {
+	case "!":
+		p.next()
+		var r TagQuery
+		if r, err = p.not(); err != nil {
+			return
+		}
+		return func(t map[string]bool) bool { return !r(t) }, nil
+	case "(":
+		p.next()
+		if q, err = p.or(); err != nil {
+			return
+		}
+		if p.tok != ")" {
+			return nil, p.fail("expected ')'")
+		}
+		p.next()
+		return
+	case "":
+		return nil, p.fail("expected a tag")
+	}
+	if r, _ := utf8.DecodeRuneInString(p.tok); !isTagChar(r) {
+		return nil, p.fail("expected a tag, found '%s'", p.tok)
+	}
+	tag := util.Norm(p.tok)
+	p.next()
+	return func(t map[string]bool) bool { return t[tag] }, nil
+}
+
+// tagQueries parses several tag expressions, such as from repeated --tag
+// flags, into a query selecting options matching any of them.
+func tagQueries(exprs []string) (q TagQuery, err error) {
+	if len(exprs) < 1 {
+		return ParseTagQuery("")
+	}
+	qs := make([]TagQuery, len(exprs))
+	for i := range exprs {
+		if qs[i], err = ParseTagQuery(exprs[i]); err != nil {
+			return
+		}
+	}
+	return func(t map[string]bool) bool {
+		for i := range qs {
+			if qs[i](t) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// Tagged is an option with its path.
+type Tagged struct {
+	Path path.Path
+	config.Option
+}
+
+// SelectTags returns the options in the tree under the Command whose tags
+// match a tag expression, see ParseTagQuery, sorted by path.
+func (c *Command) SelectTags(expr string) (sel []Tagged, err error) {
+	var q TagQuery
+	if q, err = ParseTagQuery(expr); err != nil {
+		return
+	}
+	return c.selectTags(q), nil
+}
+
+func (c *Command) selectTags(q TagQuery) (sel []Tagged) {
+	c.ForEach(func(cmd *Command, _ int) bool {
+		for name, op := range cmd.Configs {
+			if q.Match(op.Meta().Tags()) {
+				sel = append(sel, Tagged{cmd.Path.Child(name), op})
+			}
+		}
+		return true
+	}, 0, 0, c)
+	sort.Slice(sel, func(i, j int) bool {
+		return util.Norm(sel[i].Path.String()) <
+			util.Norm(sel[j].Path.String())
+	})
+	return
+}
+
+// TagCount is a tag and the number of options that have it.
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// TagCounts returns the tags of the options in the tree under the Command,
+// with the number of options with each, sorted by tag. Tags differing only
+// in case are counted together, under the first spelling found.
+func (c *Command) TagCounts() (counts []TagCount) {
+	index := make(map[string]int)
+	all, _ := ParseTagQuery("")
+	for _, t := range c.selectTags(all) {
+		for _, tag := range t.Meta().Tags() {
+			i, ok := index[util.Norm(tag)]
+			if !ok {
+				i = len(counts)
+				index[util.Norm(tag)] = i
+				counts = append(counts, TagCount{Tag: tag})
+			}
+			counts[i].Count++
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return util.Norm(counts[i].Tag) < util.Norm(counts[j].Tag)
+	})
+	return
+}
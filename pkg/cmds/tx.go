@@ -0,0 +1,144 @@
+package cmds
+
+import (
+	"fmt"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/path"
+)
+
+// Tx is a set of new values for options that Update applies together.
+type Tx struct {
+	c     *Command
+	steps []step
+}
+
+// step is a value staged in a Tx.
+type step struct {
+	path  path.Path
+	op    config.Option
+	value string
+}
+
+// Set stages a new value for the option at a path, such as
+// "pod123 node RPCConnect". Setting a path again replaces the staged value,
+// keeping the place of the first Set in the order the hooks are run.
+func (tx *Tx) Set(p path.Path, value string) (err error) {
+	op := tx.c.GetOpt(p)
+	if op == nil {
+		return fmt.Errorf("no option at '%s'", p)
+	}
+	for i := range tx.steps {
+		if tx.steps[i].op == op {
+			tx.steps[i].value = value
+			return
+		}
+	}
+	tx.steps = append(tx.steps, step{p, op, value})
+	return
+}
+
+// Get returns the value staged for the option at a path, or if none is, its
+// current value as the option prints it.
+func (tx *Tx) Get(p path.Path) (value string, err error) {
+	op := tx.c.GetOpt(p)
+	if op == nil {
+		return "", fmt.Errorf("no option at '%s'", p)
+	}
+	for i := range tx.steps {
+		if tx.steps[i].op == op {
+			return tx.steps[i].value, nil
+		}
+	}
+	return op.String(), nil
+}
+
+// Update applies a group of changes to options as one. The function stages
+// the new values with Set, and if it returns an error nothing is changed.
+//
+// Otherwise all the staged values are checked, and if any is not valid, the
+// Diagnostics listing each problem are returned and nothing is changed. The
+// values are then set one at a time in the order they were staged, running
+// the hooks of each. If a value or hook fails, the options set so far get
+// their previous values back, in the reverse order, with their hooks run
+// again, and the error is returned.
+//
+// Subscribers receive the changes of a committed Update together, in one
+// call, and nothing for an Update that failed. Updates are made one at a
+// time.
+func (c *Command) Update(fn func(tx *Tx) error) (err error) {
+	r := c.root()
+	r.update.Lock()
+	defer r.update.Unlock()
+	tx := &Tx{c: r}
+	if err = fn(tx); err != nil {
+		return
+	}
+	var diags Diagnostics
+	for _, s := range tx.steps {
+		if ch, ok := s.op.(opts.Checker); ok {
+			if e := ch.Check(s.value); e != nil {
+				diags = append(diags, Diagnostic{Path: s.path,
+					Message: fmt.Sprintf("%s: %v", s.path, e)})
+			}
+		}
+	}
+	if len(diags) > 0 {
+		return diags
+	}
+	var changes []Change
+	ops := make([]config.Option, len(tx.steps))
+	for i := range tx.steps {
+		ops[i] = tx.steps[i].op
+	}
+	held := r.hold(ops)
+	defer func() { r.release(changes) }()
+	old := make([]string, len(tx.steps))
+	for i, s := range tx.steps {
+		old[i] = storedValue(s.op)
+		if err = s.op.FromString(s.value); err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s: %w", s.path, err)
+		log.E.Ln("rolling back update:", err)
+		for j := i; j >= 0; j-- {
+			e := tx.steps[j].op.FromString(old[j])
+			log.E.Chk(e)
+		}
+		return
+	}
+	for i, s := range tx.steps {
+		// secrets print the same before and after, so compare the values
+		if held[i].Path != nil && storedValue(s.op) != old[i] {
+			changes = append(changes, *held[i])
+		}
+	}
+	return
+}
+
+// hold makes the changes of options wait for an Update to finish, returning
+// where the change of each is kept.
+func (c *Command) hold(ops []config.Option) (held []*Change) {
+	w := &c.watch
+	w.Lock()
+	defer w.Unlock()
+	w.held = make(map[config.Option]*Change, len(ops))
+	held = make([]*Change, len(ops))
+	for i := range ops {
+		held[i] = &Change{}
+		w.held[ops[i]] = held[i]
+	}
+	return
+}
+
+// release ends an Update, sending the changes it committed.
+func (c *Command) release(changes []Change) {
+	w := &c.watch
+	w.Lock()
+	defer w.Unlock()
+	w.held = nil
+	if len(changes) > 0 {
+		w.send(changes)
+	}
+}
@@ -0,0 +1,481 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cybriq/proc/pkg/opts"
+	integer "github.com/cybriq/proc/pkg/opts/Integer"
+	"github.com/cybriq/proc/pkg/opts/cidr"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/duration"
+	"github.com/cybriq/proc/pkg/opts/endpoint"
+	"github.com/cybriq/proc/pkg/opts/enum"
+	"github.com/cybriq/proc/pkg/opts/float"
+	"github.com/cybriq/proc/pkg/opts/ip"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/mapopt"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/opts/secret"
+	"github.com/cybriq/proc/pkg/opts/size"
+	"github.com/cybriq/proc/pkg/opts/text"
+	"github.com/cybriq/proc/pkg/opts/toggle"
+	"github.com/cybriq/proc/pkg/opts/units"
+	"github.com/cybriq/proc/pkg/opts/urlopt"
+	path2 "github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
+	"github.com/naoina/toml"
+	"github.com/naoina/toml/ast"
+)
+
+// Diagnostic is a problem found in a configuration file. Line and Column are
+// zero if the position is not known, such as for values added by a
+// Migration.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Path    path2.Path
+	Message string
+}
+
+func (d Diagnostic) String() (s string) {
+	if d.File != "" {
+		s = d.File + ":"
+	}
+	if d.Line > 0 {
+		s += fmt.Sprintf("%d:%d:", d.Line, d.Column)
+	}
+	if s != "" {
+		s += " "
+	}
+	return s + d.Message
+}
+
+// Diagnostics is a list of problems, and an error that prints all of them.
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	s := make([]string, len(d))
+	for i := range d {
+		s[i] = d[i].String()
+	}
+	return strings.Join(s, "\n")
+}
+
+// position is the location of a key in a configuration file.
+type position struct {
+	line, column int
+}
+
+// positions maps the normalised paths of all keys and tables in a parsed
+// configuration file to their location.
+type positions map[string]position
+
+func (p positions) get(pp path2.Path) position {
+	return p[util.Norm(pp.String())]
+}
+
+// getPositions walks a parsed file and records the location of every key and
+// table.
+func getPositions(data []byte, t *ast.Table) (p positions) {
+	p = positions{}
+	lines := strings.Split(string(data), "\n")
+	var walk func(pp path2.Path, t *ast.Table)
+	walk = func(pp path2.Path, t *ast.Table) {
+		for k, v := range t.Fields {
+			kp := pp.Child(k)
+			switch f := v.(type) {
+			case *ast.KeyValue:
+				col := 1
+				if f.Line > 0 && f.Line <= len(lines) {
+					col = strings.Index(lines[f.Line-1], k) + 1
+				}
+				p[util.Norm(kp.String())] = position{f.Line, col}
+			case *ast.Table:
+				p[util.Norm(kp.String())] = position{f.Line, 1}
+				walk(kp, f)
+			}
+		}
+	}
+	walk(path2.Path{}, t)
+	return
+}
+
+// parseDiagnostic turns an error from the TOML parser into a Diagnostic.
+func parseDiagnostic(file string, err error) Diagnostic {
+	d := Diagnostic{File: file, Message: err.Error()}
+	var le *toml.LineError
+	if errors.As(err, &le) {
+		d.Line, d.Column = le.Line, 1
+		d.Message = le.Err.Error()
+	}
+	return d
+}
+
+// strict returns true if configuration problems should be errors rather than
+// warnings.
+func (c *Command) strict() bool {
+	if op := c.GetOpt(path2.Path{c.Name, "Strict"}); op != nil {
+		return op.Value().Bool()
+	}
+	return false
+}
+
+// setting is a value from a configuration file ready to be stored in an
+// option.
+type setting struct {
+	op    config.Option
+	apply func()
+	value interface{} // as decoded from the file
+}
+
+// check compares the values in a Document with the options in the Command
+// tree. It returns settings for each valid value, and Diagnostics for every
+// unknown key, duplicated key, wrong type or invalid choice.
+func (c *Command) check(file string, doc Document,
+	pos positions) (set []setting, diags Diagnostics) {
+
+	// map options hold tables as their values
+	oo := walk([]string{}, map[string]interface{}(doc), []Entry{},
+		func(p path2.Path) bool {
+			op := c.GetOpt(p)
+			return op != nil && op.Type() == meta.Map
+		})
+	sort.Sort(oo)
+	seen := make(map[string]Entry)
+	for i := range oo {
+		e := oo[i]
+		if len(e.path) == 1 && e.name == SchemaVersionKey {
+			continue
+		}
+		at := pos.get(e.path)
+		diag := func(format string, a ...interface{}) {
+			diags = append(diags, Diagnostic{
+				File: file, Line: at.line, Column: at.column,
+				Path: e.path, Message: fmt.Sprintf(format, a...),
+			})
+		}
+		op := c.GetOpt(e.path)
+		if op == nil {
+			diag("unknown option %s%s", e.path, c.suggestOpt(e.path))
+			continue
+		}
+		norm := util.Norm(e.path.String())
+		if prev, ok := seen[norm]; ok {
+			diag("duplicate option %s, also set as %s", e.path, prev.path)
+			continue
+		}
+		seen[norm] = e
+		fn, err := setter(op, e.value)
+		if err != nil {
+			diag("%s: %v", e.path, err)
+			continue
+		}
+		// enums check their own choices, with aliases
+		if choices := op.Meta().Options(); len(choices) > 0 &&
+			op.Type() != meta.Enum {
+
+			if s, ok := e.value.(string); ok && !isChoice(s, choices) {
+				diag("%s: invalid value '%s', valid choices are: %s",
+					e.path, s, strings.Join(choices, ", "))
+				continue
+			}
+		}
+		set = append(set, setting{op, fn, e.value})
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Line < diags[j].Line
+	})
+	return
+}
+
+// isChoice returns true if the value is one of the choices, ignoring case.
+func isChoice(v string, choices []string) bool {
+	for i := range choices {
+		if util.Norm(v) == util.Norm(choices[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestOpt finds the closest option or command name to the last element of
+// a path that was not found.
+func (c *Command) suggestOpt(p path2.Path) string {
+	if len(p) < 2 {
+		return ""
+	}
+	parent := c.GetCommand(p.Parent().String())
+	if parent == nil {
+		// the table does not exist, check for a misspelled command
+		if grand := c.GetCommand(p.Parent().Parent().String()); grand != nil {
+			var names []string
+			for i := range grand.Commands {
+				names = append(names, grand.Commands[i].Name)
+			}
+			if s := util.Suggest(p[len(p)-2], names); s != "" {
+				return fmt.Sprintf(", did you mean table [%s]?",
+					strings.Join(grand.Path.Child(s), "."))
+			}
+		}
+		return ""
+	}
+	var names []string
+	for i := range parent.Configs {
+		names = append(names, i)
+	}
+	if s := util.Suggest(p[len(p)-1], names); s != "" {
+		return fmt.Sprintf(", did you mean %s?", s)
+	}
+	return ""
+}
+
+// setter returns a function that sets an option to a value decoded from a
+// configuration file, or an error if the value is not of a usable type. The
+// values are stored without running the hooks, as is done by Init.
+func setter(op config.Option, value interface{}) (fn func(), err error) {
+	mismatch := func(want string) error {
+		return fmt.Errorf("expected %s, found %s", want, tomlType(value))
+	}
+	switch op.Type() {
+	case meta.Bool:
+		v, ok := value.(bool)
+		if !ok {
+			return nil, mismatch("a boolean")
+		}
+		return checked(op, v, func() { op.(*toggle.Opt).FromValue(v) })
+	case meta.CIDR:
+		o := op.(*cidr.Opt)
+		str, e := stringItems(value, o.IsMulti(), mismatch)
+		if e != nil {
+			return nil, e
+		}
+		v, e := o.Parse(str)
+		if e != nil {
+			return nil, e
+		}
+		return checked(op, v, func() { o.FromValue(v...) })
+	case meta.Duration:
+		s, ok := value.(string)
+		if !ok {
+			return nil, mismatch("a duration string such as \"1m30s\"")
+		}
+		v, e := time.ParseDuration(strings.TrimSpace(s))
+		if e != nil {
+			return nil, fmt.Errorf("invalid duration '%s'", s)
+		}
+		return checked(op, v, func() { op.(*duration.Opt).FromValue(v) })
+	case meta.Endpoint:
+		o := op.(*endpoint.Opt)
+		str, e := stringItems(value, o.IsMulti(), mismatch)
+		if e != nil {
+			return nil, e
+		}
+		v, e := o.Parse(str)
+		if e != nil {
+			return nil, e
+		}
+		return checked(op, v, func() { o.FromValue(v...) })
+	case meta.Enum:
+		o := op.(*enum.Opt)
+		str, e := stringItems(value, o.IsMulti(), mismatch)
+		if e != nil {
+			return nil, e
+		}
+		v, e := o.Parse(str)
+		if e != nil {
+			return nil, e
+		}
+		return checked(op, v, func() { o.FromValue(v...) })
+	case meta.Float:
+		o := op.(*float.Opt)
+		switch v := value.(type) {
+		case float64:
+			return checked(op, v, func() { o.FromValue(v) })
+		case int64:
+			f := float64(v)
+			return checked(op, f, func() { o.FromValue(f) })
+		case string:
+			if o.HasUnits() {
+				f, e := units.ParseFloat(v)
+				if e != nil {
+					return nil, e
+				}
+				return checked(op, f, func() { o.FromValue(f) })
+			}
+		}
+		return nil, mismatch("a number")
+	case meta.IP:
+		str, ok := value.(string)
+		if !ok {
+			return nil, mismatch("a string")
+		}
+		v, e := ip.Parse(str)
+		if e != nil {
+			return nil, e
+		}
+		return checked(op, v, func() { op.(*ip.Opt).FromValue(v) })
+	case meta.Integer:
+		o := op.(*integer.Opt)
+		switch v := value.(type) {
+		case int64:
+			return checked(op, v, func() { o.FromValue(v) })
+		case string:
+			if o.HasUnits() {
+				n, e := units.ParseInt(v)
+				if e != nil {
+					return nil, e
+				}
+				return checked(op, n, func() { o.FromValue(n) })
+			}
+		}
+		return nil, mismatch("an integer")
+	case meta.List:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, mismatch("an array of strings")
+		}
+		v := make([]string, 0, len(items))
+		for i := range items {
+			s, ok := items[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("expected an array of strings, "+
+					"item %d is %s", i+1, tomlType(items[i]))
+			}
+			v = append(v, s)
+		}
+		o := op.(*list.Opt)
+		return checkedString(op, list.Join(v, o.Sep()),
+			func() { o.FromValue(v) })
+	case meta.Map:
+		o := op.(*mapopt.Opt)
+		t, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, mismatch("a table")
+		}
+		v := make(map[string]string, len(t))
+		for k := range t {
+			switch tv := t[k].(type) {
+			case string:
+				v[k] = tv
+			case bool, int64, float64:
+				v[k] = fmt.Sprint(tv)
+			default:
+				return nil, fmt.Errorf("value of '%s' is %s", k, tomlType(tv))
+			}
+			if err = o.CheckValue(k, v[k]); err != nil {
+				return
+			}
+		}
+		return checked(op, v, func() { o.FromValue(v) })
+	case meta.Secret:
+		v, ok := value.(string)
+		if !ok {
+			return nil, mismatch("a string")
+		}
+		return checkedString(op, v, func() { op.(*secret.Opt).FromValue(v) })
+	case meta.Size:
+		switch v := value.(type) {
+		case int64:
+			return checked(op, v, func() { op.(*size.Opt).FromValue(v) })
+		case string:
+			n, e := units.ParseSize(v)
+			if e != nil {
+				return nil, e
+			}
+			return checked(op, n, func() { op.(*size.Opt).FromValue(n) })
+		}
+		return nil, mismatch("a size or a number of bytes")
+	case meta.Text:
+		v, ok := value.(string)
+		if !ok {
+			return nil, mismatch("a string")
+		}
+		return checkedString(op, v, func() { op.(*text.Opt).FromValue(v) })
+	case meta.URL:
+		str, ok := value.(string)
+		if !ok {
+			return nil, mismatch("a string")
+		}
+		v, e := op.(*urlopt.Opt).Parse(str)
+		if e != nil {
+			return nil, e
+		}
+		return checked(op, v, func() { op.(*urlopt.Opt).FromValue(v) })
+	}
+	return nil, fmt.Errorf("option type %s unknown", op.Type())
+}
+
+// checked returns the function that applies a value to an option, or the
+// error of the first validator of the option that rejects the value.
+func checked(op config.Option, v interface{}, apply func()) (func(), error) {
+	if err := op.Meta().Check(v); err != nil {
+		return nil, err
+	}
+	return apply, nil
+}
+
+// checkedString is checked for the options that check a value as their hooks
+// see it, with the references in it resolved, which is given in the form
+// FromString reads.
+func checkedString(op config.Option, s string, apply func()) (func(),
+	error) {
+
+	if err := op.(opts.Checker).Check(s); err != nil {
+		return nil, err
+	}
+	return apply, nil
+}
+
+// stringItems returns a string value, or if multi is set, the items of an
+// array of strings joined with commas.
+func stringItems(value interface{}, multi bool,
+	mismatch func(want string) error) (s string, err error) {
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []interface{}:
+		if !multi {
+			return "", mismatch("a string")
+		}
+		items := make([]string, len(v))
+		for i := range v {
+			var ok bool
+			if items[i], ok = v[i].(string); !ok {
+				return "", fmt.Errorf("expected an array of strings, "+
+					"item %d is %s", i+1, tomlType(v[i]))
+			}
+		}
+		return strings.Join(items, ","), nil
+	}
+	if multi {
+		return "", mismatch("an array of strings")
+	}
+	return "", mismatch("a string")
+}
+
+// tomlType names the TOML type of a decoded value for messages.
+func tomlType(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "a boolean"
+	case string:
+		return "a string"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "a table"
+	case time.Time:
+		return "a datetime"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,245 @@
+package cmds
+
+import (
+	"sync"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/util"
+)
+
+// Change is a change of the value of an option, with the values before and
+// after as the option prints them, so the values of secrets are redacted.
+type Change struct {
+	// Path is the path of the option, ending with its name.
+	Path     path.Path
+	Old, New string
+}
+
+// watchers are the subscribers to changes of the options of a tree, kept on
+// the root Command, with the changes held back by an Update in progress.
+type watchers struct {
+	sync.Mutex
+	subs []*subscriber
+	held map[config.Option]*Change
+}
+
+// subscriber delivers the changes under a path to a function, in the order
+// they were made, one at a time, from its own goroutine, so a slow function
+// does not hold up the option being changed.
+type subscriber struct {
+	sync.Mutex
+	prefix path.Path
+	fn     func([]Change)
+	queue  [][]Change
+	wake   chan struct{}
+	done   chan struct{}
+	stop   sync.Once
+}
+
+// root returns the Command at the top of the tree.
+func (c *Command) root() (r *Command) {
+	for r = c; r.Parent != nil; r = r.Parent {
+	}
+	return
+}
+
+// setNotifiers connects every option in the tree that supports it to the
+// subscribers of the root, and to the options that refer to it, which are
+// expanded again when it changes. It must be called on the root Command.
+func (c *Command) setNotifiers() {
+	c.ForEach(func(cmd *Command, _ int) bool {
+		for i := range cmd.Configs {
+			if o, ok := cmd.Configs[i].(opts.Notifying); ok {
+				cmd, name, op := cmd, i, cmd.Configs[i]
+				o.SetNotifier(func(old, new string) {
+					c.notify(op, Change{cmd.Path.Child(name), old, new})
+					c.expandReferrers(op, nil)
+				})
+			}
+		}
+		return true
+	}, 0, 0, c)
+}
+
+// notify queues a change for the subscribers to its path, or if the option
+// is part of an Update in progress, holds it until the Update is committed.
+func (c *Command) notify(op config.Option, ch Change) {
+	w := &c.watch
+	w.Lock()
+	defer w.Unlock()
+	if held, ok := w.held[op]; ok {
+		if held.Path == nil {
+			*held = ch
+		} else {
+			held.New = ch.New
+		}
+		return
+	}
+	w.send([]Change{ch})
+}
+
+// send queues changes for the subscribers, each receiving the ones under its
+// path together.
+func (w *watchers) send(changes []Change) {
+	for _, s := range w.subs {
+		var batch []Change
+		for _, ch := range changes {
+			if under(ch.Path, s.prefix) {
+				batch = append(batch, ch)
+			}
+		}
+		if len(batch) > 0 {
+			s.send(batch)
+		}
+	}
+}
+
+// Subscribe calls a function with the values before and after each change of
+// the option at a path, such as "pod123 node P2PListeners", or of any option
+// under the path of a Command. Calls for one subscription are made one at a
+// time in the order of the changes, from another goroutine. Calling the
+// returned function ends the subscription, though a call in progress
+// completes.
+func (c *Command) Subscribe(p path.Path, fn func(old, new string)) (
+	unsubscribe func()) {
+
+	return c.SubscribeChanges(p, func(changes []Change) {
+		for _, ch := range changes {
+			fn(ch.Old, ch.New)
+		}
+	})
+}
+
+// SubscribeChanges is Subscribe with the paths of the options that changed,
+// for subscriptions to a subtree. The changes committed by an Update are
+// received in one call, in the order the Update set them.
+func (c *Command) SubscribeChanges(p path.Path, fn func([]Change)) (
+	unsubscribe func()) {
+
+	return c.subscribe(p, fn, nil)
+}
+
+// Watch returns a channel receiving the changes of the options at or under a
+// path, as SubscribeChanges, and the function that ends the watch and closes
+// the channel. Changes are sent in order, and wait for the channel to be
+// read.
+func (c *Command) Watch(p path.Path) (changes <-chan []Change,
+	cancel func()) {
+
+	out, done := make(chan []Change), make(chan struct{})
+	unsubscribe := c.subscribe(p, func(changes []Change) {
+		select {
+		case out <- changes:
+		case <-done:
+		}
+	}, func() { close(out) })
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+}
+
+// subscribe adds a subscriber, which calls stop, if it is given, when its
+// goroutine ends.
+func (c *Command) subscribe(p path.Path, fn func([]Change), stop func()) (
+	unsubscribe func()) {
+
+	r := c.root()
+	if !r.hasOptionsUnder(p) {
+		log.W.Ln("no options to watch under", p)
+	}
+	s := &subscriber{
+		prefix: p,
+		fn:     fn,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	w := &r.watch
+	w.Lock()
+	w.subs = append(w.subs, s)
+	w.Unlock()
+	go s.run(stop)
+	return func() {
+		w.Lock()
+		for i := range w.subs {
+			if w.subs[i] == s {
+				w.subs = append(w.subs[:i:i], w.subs[i+1:]...)
+				break
+			}
+		}
+		w.Unlock()
+		s.stop.Do(func() { close(s.done) })
+	}
+}
+
+// hasOptionsUnder returns true if there is an option at or under a path.
+func (c *Command) hasOptionsUnder(p path.Path) (found bool) {
+	c.ForEach(func(cmd *Command, _ int) bool {
+		for i := range cmd.Configs {
+			if under(cmd.Path.Child(i), p) {
+				found = true
+				return false
+			}
+		}
+		return true
+	}, 0, 0, c)
+	return
+}
+
+// under returns true if a path is a prefix of, or the same as, another,
+// ignoring case.
+func under(p, prefix path.Path) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if util.Norm(p[i]) != util.Norm(prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *subscriber) send(changes []Change) {
+	s.Lock()
+	s.queue = append(s.queue, changes)
+	s.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) run(stop func()) {
+	if stop != nil {
+		defer stop()
+	}
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+		for {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.Lock()
+			if len(s.queue) < 1 {
+				s.Unlock()
+				break
+			}
+			changes := s.queue[0]
+			s.queue = s.queue[1:]
+			s.Unlock()
+			s.fn(changes)
+		}
+	}
+}
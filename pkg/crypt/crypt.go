@@ -0,0 +1,105 @@
+// Package crypt implements the container format for encrypted configuration
+// files.
+//
+// A container is the Magic header, a random scrypt salt, a random
+// XChaCha20-Poly1305 nonce and then the sealed plaintext. The header and salt
+// are authenticated along with the ciphertext so any change to the file is
+// detected when it is opened.
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Magic is the header that identifies an encrypted configuration file.
+var Magic = []byte("PROCENC1")
+
+const (
+	// SaltLen is the length of the scrypt salt.
+	SaltLen = 16
+	// scrypt cost parameters, as recommended for interactive logins.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// ErrNotEncrypted is returned when opening data without the Magic header.
+	ErrNotEncrypted = errors.New("data is not an encrypted container")
+	// ErrDecrypt is returned when the passphrase is wrong or the data has been
+	// altered.
+	ErrDecrypt = errors.New("wrong passphrase or corrupted data")
+)
+
+// IsEncrypted returns true if the data starts with the Magic header.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, Magic)
+}
+
+// Key derives the encryption key from a passphrase and salt.
+func Key(passphrase, salt []byte) (key []byte, err error) {
+	return scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP,
+		chacha20poly1305.KeySize)
+}
+
+// Encrypt seals the plaintext into a new container with a fresh salt and
+// nonce.
+func Encrypt(plaintext, passphrase []byte) (data []byte, err error) {
+	if len(passphrase) < 1 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	header := make([]byte, len(Magic)+SaltLen)
+	copy(header, Magic)
+	salt := header[len(Magic):]
+	if _, err = rand.Read(salt); log.E.Chk(err) {
+		return
+	}
+	var key []byte
+	if key, err = Key(passphrase, salt); log.E.Chk(err) {
+		return
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if log.E.Chk(err) {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); log.E.Chk(err) {
+		return
+	}
+	data = append(header, nonce...)
+	data = aead.Seal(data, nonce, plaintext, header)
+	return
+}
+
+// Decrypt opens a container made by Encrypt.
+func Decrypt(data, passphrase []byte) (plaintext []byte, err error) {
+	if !IsEncrypted(data) {
+		return nil, ErrNotEncrypted
+	}
+	hl := len(Magic) + SaltLen
+	if len(data) < hl+chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+		return nil, ErrDecrypt
+	}
+	header, salt := data[:hl], data[len(Magic):hl]
+	var key []byte
+	if key, err = Key(passphrase, salt); log.E.Chk(err) {
+		return
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if log.E.Chk(err) {
+		return
+	}
+	nonce := data[hl : hl+aead.NonceSize()]
+	if plaintext, err = aead.Open(nil, nonce, data[hl+aead.NonceSize():],
+		header); err != nil {
+
+		return nil, ErrDecrypt
+	}
+	return
+}
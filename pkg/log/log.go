@@ -293,7 +293,7 @@ func logPrint(
 			}
 			var app string
 			if len(App) > 0 {
-				fmt.Sprint(" [" + App + "]")
+				app = fmt.Sprint(" [" + App + "]")
 			}
 			s := fmt.Sprintf(
 				formatString,
@@ -2,96 +2,108 @@ package integer
 
 import (
 	"strconv"
-	"strings"
 
+	"github.com/cybriq/proc/pkg/opts"
 	"github.com/cybriq/proc/pkg/opts/config"
 	"github.com/cybriq/proc/pkg/opts/meta"
-	"github.com/cybriq/proc/pkg/path"
+	"github.com/cybriq/proc/pkg/opts/units"
 	"go.uber.org/atomic"
 )
 
 type Opt struct {
-	p path.Path
-	m meta.Metadata
-	v atomic.Int64
+	*opts.Typed[int64]
+	u atomic.Bool
 	h []Hook
 }
 
-func (o *Opt) Path() (p path.Path) {
-	return o.p
-}
-
-func (o *Opt) SetPath(p path.Path) {
-	o.p = p
-}
-
 var _ config.Option = &Opt{}
 
 type Hook func(*Opt) error
 
 func New(m meta.Data, h ...Hook) (o *Opt) {
-	o = &Opt{m: meta.New(m, meta.Integer), h: h}
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Integer), o.parse, o.format,
+		func(c *config.Concrete, v func() int64) { c.Integer = v })
+	o.SetHooks(o.RunHooks)
 	_ = o.FromString(m.Default)
 	return
 }
 
-func (o *Opt) Meta() meta.Metadata     { return o.m }
-func (o *Opt) Type() meta.Type         { return o.m.Typ }
-func (o *Opt) ToOption() config.Option { return o }
-
-func (o *Opt) RunHooks() (e error) {
-	for i := range o.h {
-		e = o.h[i](o)
-		if e != nil {
-			return
-		}
+// Units lets the value be written with the SI and IEC suffixes of the units
+// package, such as 64k or 4Mi, with underscores between digits, or as a
+// hexadecimal, octal or binary literal. The value is then printed with the
+// largest suffix that represents it exactly.
+func (o *Opt) Units() *Opt {
+	o.u.Store(true)
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
 	}
-	return
-}
-
-func (o *Opt) FromValue(v int64) *Opt {
-	o.v.Store(v)
 	return o
 }
 
-func (o *Opt) FromString(s string) (e error) {
-	s = strings.TrimSpace(s)
-	var p int64
-	p, e = strconv.ParseInt(s, 10, 64)
-	if e != nil {
-		return e
+// HasUnits returns true if the value is written with unit suffixes.
+func (o *Opt) HasUnits() bool { return o.u.Load() }
+
+func (o *Opt) parse(s string) (int64, error) {
+	if o.u.Load() {
+		return units.ParseInt(s)
 	}
-	o.v.Store(p)
-	e = o.RunHooks()
-	return
+	return strconv.ParseInt(s, 10, 64)
 }
 
-func (o *Opt) String() (s string) {
-	return strconv.FormatInt(o.v.Load(), 10)
+func (o *Opt) format(v int64) string {
+	if o.u.Load() {
+		return units.FormatInt(v)
+	}
+	return strconv.FormatInt(v, 10)
 }
 
-func (o *Opt) Expanded() (s string) {
-	return o.String()
-}
+func (o *Opt) ToOption() config.Option { return o }
 
-func (o *Opt) SetExpanded(s string) {
-	err := o.FromString(s)
-	log.E.Chk(err)
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
 }
 
-func (o *Opt) Value() (c config.Concrete) {
-	c = config.NewConcrete()
-	c.Integer = func() int64 { return o.v.Load() }
-	return
+func (o *Opt) FromValue(v int64) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
 }
 
-func Clamp(o *Opt, min, max int64) func(*Opt) {
-	return func(o *Opt) {
-		v := o.v.Load()
+// Clamp is a Hook that keeps the value between min and max.
+func Clamp(min, max int64) Hook {
+	return func(o *Opt) error {
+		v := o.Load()
 		if v < min {
-			o.v.Store(min)
+			o.Store(min)
 		} else if v > max {
-			o.v.Store(max)
+			o.Store(max)
+		}
+		return nil
+	}
+}
+
+// ClampUnits is a Hook that keeps the value between min and max, which are
+// written in the same form as a value with Units, such as 4k or 1Mi.
+func ClampUnits(min, max string) Hook {
+	lo, e := units.ParseInt(min)
+	if log.E.Chk(e) {
+		return func(*Opt) error { return e }
+	}
+	hi, e := units.ParseInt(max)
+	if log.E.Chk(e) {
+		return func(*Opt) error { return e }
+	}
+	return func(o *Opt) error {
+		v := o.Load()
+		if v < lo {
+			log.W.F("%s is below the minimum, using %s", units.FormatInt(v),
+				min)
+			o.Store(lo)
+		} else if v > hi {
+			log.W.F("%s is above the maximum, using %s", units.FormatInt(v),
+				max)
+			o.Store(hi)
 		}
+		return nil
 	}
 }
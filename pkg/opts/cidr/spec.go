@@ -0,0 +1,146 @@
+package cidr
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+)
+
+// Opt is an option holding an IP network written in CIDR notation, such as
+// 10.0.0.0/8, or with Multi, any number of them. A bare address is taken to
+// be a network of that one address.
+type Opt struct {
+	*opts.Typed[[]*net.IPNet]
+	multi bool
+	h     []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.CIDR), o.parse, format,
+		func(c *config.Concrete, v func() []*net.IPNet) {
+			c.Text = func() string {
+				if v := v(); len(v) > 0 {
+					return v[0].String()
+				}
+				return ""
+			}
+			c.List = func() []string { return strs(v()) }
+		})
+	o.SetHooks(o.RunHooks)
+	_ = o.FromString(m.Default)
+	return
+}
+
+// Multi allows any number of networks, separated by commas.
+func (o *Opt) Multi() *Opt {
+	o.multi = true
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
+	}
+	return o
+}
+
+func (o *Opt) ToOption() config.Option { return o }
+
+// IsMulti returns true if more than one network can be given.
+func (o *Opt) IsMulti() bool { return o.multi }
+
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
+}
+
+func (o *Opt) FromValue(v ...*net.IPNet) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
+}
+
+// ParseNetwork reads a network in CIDR notation, or a single address.
+func ParseNetwork(s string) (n *net.IPNet, e error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("'%s' is not an IP address or a "+
+				"network in CIDR notation", s)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	addr, size, _ := strings.Cut(s, "/")
+	if net.ParseIP(addr) == nil {
+		return nil, fmt.Errorf("'%s' in network '%s' is not an IP address",
+			addr, s)
+	}
+	if _, n, e = net.ParseCIDR(s); e != nil {
+		return nil, fmt.Errorf("'/%s' in network '%s' is not a valid "+
+			"prefix length", size, s)
+	}
+	return
+}
+
+// parse reads the networks of a value. An empty string is no networks.
+func (o *Opt) parse(s string) (v []*net.IPNet, e error) {
+	v = []*net.IPNet{}
+	if s == "" {
+		return
+	}
+	items := []string{s}
+	if o.multi {
+		items = strings.Split(s, ",")
+	}
+	for _, item := range items {
+		var n *net.IPNet
+		if n, e = ParseNetwork(item); e != nil {
+			return nil, e
+		}
+		v = append(v, n)
+	}
+	return
+}
+
+func format(v []*net.IPNet) string {
+	return strings.Join(strs(v), ",")
+}
+
+func strs(v []*net.IPNet) (s []string) {
+	s = make([]string, len(v))
+	for i := range v {
+		s[i] = v[i].String()
+	}
+	return
+}
+
+// Network returns the first network, or nil if there is none.
+func (o *Opt) Network() *net.IPNet {
+	if v := o.Load(); len(v) > 0 {
+		return v[0]
+	}
+	return nil
+}
+
+// Networks returns all the networks.
+func (o *Opt) Networks() []*net.IPNet {
+	return append([]*net.IPNet{}, o.Load()...)
+}
+
+// Contains returns true if one of the networks contains the address.
+func (o *Opt) Contains(ip net.IP) bool {
+	for _, n := range o.Load() {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,6 +15,7 @@ type Concrete struct {
 	Float    func() float64
 	Integer  func() int64
 	List     func() []string
+	Map      func() map[string]string
 	Text     func() string
 }
 
@@ -26,6 +27,7 @@ func NewConcrete() Concrete {
 		func() float64 { return 0 },
 		func() int64 { return 0 },
 		func() []string { return nil },
+		func() map[string]string { return nil },
 		func() string { return "" },
 	}
 }
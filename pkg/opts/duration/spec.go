@@ -1,95 +1,52 @@
 package duration
 
 import (
-	"fmt"
-	"strings"
 	"time"
 
+	"github.com/cybriq/proc/pkg/opts"
 	"github.com/cybriq/proc/pkg/opts/config"
 	"github.com/cybriq/proc/pkg/opts/meta"
-	"github.com/cybriq/proc/pkg/path"
-	"go.uber.org/atomic"
 )
 
 type Opt struct {
-	p path.Path
-	m meta.Metadata
-	v atomic.Duration
+	*opts.Typed[time.Duration]
 	h []Hook
 }
 
-func (o *Opt) Path() (p path.Path) {
-	return o.p
-}
-
-func (o *Opt) SetPath(p path.Path) {
-	o.p = p
-}
-
 var _ config.Option = &Opt{}
 
-type Hook func(*Opt)
+type Hook func(*Opt) error
 
 func New(m meta.Data, h ...Hook) (o *Opt) {
-	o = &Opt{m: meta.New(m, meta.Duration), h: h}
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Duration), time.ParseDuration,
+		time.Duration.String,
+		func(c *config.Concrete, v func() time.Duration) { c.Duration = v })
+	o.SetHooks(o.RunHooks)
 	_ = o.FromString(m.Default)
 	return
 }
 
-func (o *Opt) Meta() meta.Metadata     { return o.m }
-func (o *Opt) Type() meta.Type         { return o.m.Typ }
 func (o *Opt) ToOption() config.Option { return o }
 
 func (o *Opt) RunHooks() (e error) {
-	for i := range o.h {
-		o.h[i](o)
-	}
-	return
+	return opts.RunHooks(o, o.h)
 }
 
 func (o *Opt) FromValue(v time.Duration) *Opt {
-	o.v.Store(v)
+	log.E.Chk(o.Set(v))
 	return o
 }
 
-func (o *Opt) FromString(s string) (e error) {
-	s = strings.TrimSpace(s)
-	var d time.Duration
-	d, e = time.ParseDuration(s)
-	if e != nil {
-		return e
-	}
-	o.v.Store(d)
-	e = o.RunHooks()
-	return
-}
-
-func (o *Opt) String() (s string) {
-	return fmt.Sprint(o.v.Load())
-}
-
-func (o *Opt) Expanded() (s string) {
-	return o.String()
-}
-
-func (o *Opt) SetExpanded(s string) {
-	err := o.FromString(s)
-	log.E.Chk(err)
-}
-
-func (o *Opt) Value() (c config.Concrete) {
-	c = config.NewConcrete()
-	c.Duration = func() time.Duration { return o.v.Load() }
-	return
-}
-
-func Clamp(o *Opt, min, max time.Duration) func(*Opt) {
-	return func(o *Opt) {
-		v := o.v.Load()
+// Clamp is a Hook that keeps the value between min and max.
+func Clamp(min, max time.Duration) Hook {
+	return func(o *Opt) error {
+		v := o.Load()
 		if v < min {
-			o.v.Store(min)
+			o.Store(min)
 		} else if v > max {
-			o.v.Store(max)
+			o.Store(max)
 		}
+		return nil
 	}
 }
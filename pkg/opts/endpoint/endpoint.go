@@ -0,0 +1,198 @@
+package endpoint
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// UnixPrefix starts an endpoint that is a unix socket path.
+	UnixPrefix = "unix:"
+	// InterfacePrefix starts an endpoint naming network interfaces, such as
+	// if:eth0:11047 or if:en*:11047.
+	InterfacePrefix = "if:"
+)
+
+// Endpoint is an address to listen on or connect to. It is either a TCP host
+// and port, a unix socket path, or a pattern matching the names of network
+// interfaces, which Expand turns into the addresses of those interfaces.
+type Endpoint struct {
+	Network   string // tcp or unix
+	Host      string // IP address or host name, empty for all addresses
+	Zone      string // IPv6 zone
+	Port      string
+	Interface string // interface name pattern, with * and ? wildcards
+	Path      string // unix socket path
+}
+
+// Address returns the address as used with net.Dial and net.Listen.
+func (e Endpoint) Address() string {
+	if e.Network == "unix" {
+		return e.Path
+	}
+	host := e.Host
+	if e.Zone != "" {
+		host += "%" + e.Zone
+	}
+	return net.JoinHostPort(host, e.Port)
+}
+
+// String returns the endpoint in the form it is parsed from.
+func (e Endpoint) String() string {
+	switch {
+	case e.Network == "unix":
+		return UnixPrefix + e.Path
+	case e.Interface != "":
+		return InterfacePrefix + e.Interface + ":" + e.Port
+	}
+	return e.Address()
+}
+
+// Parse reads an endpoint, which is one of
+//
+//	host:port, such as 127.0.0.1:11047 or example.com:11047
+//	[address%zone]:port, for IPv6 addresses, with an optional zone
+//	unix:/path/to/socket
+//	if:name:port, where name can contain the wildcards * and ?
+//
+// The port is optional if a default port is given.
+func Parse(s, defaultPort string) (ep Endpoint, e error) {
+	s = strings.TrimSpace(s)
+	ep.Network = "tcp"
+	switch {
+	case s == "":
+		return ep, fmt.Errorf("empty endpoint")
+	case strings.HasPrefix(s, UnixPrefix):
+		ep.Network, ep.Path = "unix", s[len(UnixPrefix):]
+		if ep.Path == "" {
+			e = fmt.Errorf("missing socket path in '%s'", s)
+		}
+		return
+	case strings.HasPrefix(s, InterfacePrefix):
+		ep.Interface = s[len(InterfacePrefix):]
+		if i := strings.LastIndexByte(ep.Interface, ':'); i >= 0 {
+			ep.Interface, ep.Port = ep.Interface[:i], ep.Interface[i+1:]
+		}
+		if ep.Interface == "" {
+			return ep, fmt.Errorf("missing interface name in '%s'", s)
+		}
+		if _, e = path.Match(ep.Interface, ""); e != nil {
+			return ep, fmt.Errorf("invalid interface pattern '%s' in '%s'",
+				ep.Interface, s)
+		}
+	case strings.HasPrefix(s, "["):
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return ep, fmt.Errorf("missing ']' in '%s'", s)
+		}
+		ep.Host, ep.Zone, _ = strings.Cut(s[1:end], "%")
+		if ip := net.ParseIP(ep.Host); ip == nil || ip.To4() != nil {
+			return ep, fmt.Errorf("'%s' in '%s' is not an IPv6 address",
+				ep.Host, s)
+		}
+		if rest := s[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return ep, fmt.Errorf("unexpected '%s' after ']' in '%s'",
+					rest, s)
+			}
+			ep.Port = rest[1:]
+		}
+	case strings.Count(s, ":") > 1:
+		// an IPv6 address without brackets cannot have a port
+		ep.Host, ep.Zone, _ = strings.Cut(s, "%")
+		if net.ParseIP(ep.Host) == nil {
+			return ep, fmt.Errorf("'%s' is not an IPv6 address, and a port "+
+				"with one must be written [address]:port", s)
+		}
+	default:
+		ep.Host, ep.Port, _ = strings.Cut(s, ":")
+		if e = checkHost(ep.Host); e != nil {
+			return ep, fmt.Errorf("%w in '%s'", e, s)
+		}
+	}
+	if ep.Port == "" {
+		if defaultPort == "" {
+			return ep, fmt.Errorf("missing port in '%s'", s)
+		}
+		ep.Port = defaultPort
+	}
+	if e = checkPort(ep.Port); e != nil {
+		return ep, fmt.Errorf("%w in '%s'", e, s)
+	}
+	return
+}
+
+// checkHost checks that a host is empty, an IPv4 address or a host name.
+func checkHost(host string) error {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") ||
+			strings.HasSuffix(label, "-") {
+
+			return fmt.Errorf("invalid host name '%s'", host)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+				r >= '0' && r <= '9' || r == '-' || r == '_') {
+
+				return fmt.Errorf("invalid character '%c' in host name '%s'",
+					r, host)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPort checks that a port is a number from 0 to 65535.
+func checkPort(port string) error {
+	if _, e := strconv.ParseUint(port, 10, 16); e != nil {
+		return fmt.Errorf("invalid port '%s', expected a number from 0 to "+
+			"65535", port)
+	}
+	return nil
+}
+
+// Expand returns the addresses of the interfaces an endpoint names, with its
+// port. Other endpoints are returned as they are. It is an error if no
+// interface matches.
+func (e Endpoint) Expand() (eps []Endpoint, err error) {
+	if e.Interface == "" {
+		return []Endpoint{e}, nil
+	}
+	var ifaces []net.Interface
+	if ifaces, err = net.Interfaces(); err != nil {
+		return
+	}
+	var matched bool
+	for _, iface := range ifaces {
+		if ok, _ := path.Match(e.Interface, iface.Name); !ok {
+			continue
+		}
+		matched = true
+		var addrs []net.Addr
+		if addrs, err = iface.Addrs(); err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			n, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ep := Endpoint{Network: "tcp", Host: n.IP.String(), Port: e.Port}
+			if n.IP.To4() == nil && n.IP.IsLinkLocalUnicast() {
+				ep.Zone = iface.Name
+			}
+			eps = append(eps, ep)
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("no network interface matches '%s'",
+			e.Interface)
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package endpoint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s, port string
+		ep      Endpoint
+		str     string
+		err     string
+	}{
+		{"127.0.0.1:11047", "", Endpoint{Network: "tcp", Host: "127.0.0.1",
+			Port: "11047"}, "127.0.0.1:11047", ""},
+		{" example.com:80 ", "", Endpoint{Network: "tcp",
+			Host: "example.com", Port: "80"}, "example.com:80", ""},
+		{"example.com", "11047", Endpoint{Network: "tcp",
+			Host: "example.com", Port: "11047"}, "example.com:11047", ""},
+		{":11047", "", Endpoint{Network: "tcp", Port: "11047"}, ":11047", ""},
+		{"[::1]:11047", "", Endpoint{Network: "tcp", Host: "::1",
+			Port: "11047"}, "[::1]:11047", ""},
+		{"[fe80::1%eth0]:11047", "", Endpoint{Network: "tcp",
+			Host: "fe80::1", Zone: "eth0", Port: "11047"},
+			"[fe80::1%eth0]:11047", ""},
+		{"[fe80::1%eth0]", "80", Endpoint{Network: "tcp", Host: "fe80::1",
+			Zone: "eth0", Port: "80"}, "[fe80::1%eth0]:80", ""},
+		{"fe80::1%eth0", "80", Endpoint{Network: "tcp", Host: "fe80::1",
+			Zone: "eth0", Port: "80"}, "[fe80::1%eth0]:80", ""},
+		{"::1", "80", Endpoint{Network: "tcp", Host: "::1", Port: "80"},
+			"[::1]:80", ""},
+		{"unix:/run/pod.sock", "", Endpoint{Network: "unix",
+			Path: "/run/pod.sock"}, "unix:/run/pod.sock", ""},
+		{"if:eth0:11047", "", Endpoint{Network: "tcp", Interface: "eth0",
+			Port: "11047"}, "if:eth0:11047", ""},
+		{"if:en*", "80", Endpoint{Network: "tcp", Interface: "en*",
+			Port: "80"}, "if:en*:80", ""},
+		{"", "80", Endpoint{}, "", "empty endpoint"},
+		{"unix:", "", Endpoint{}, "", "missing socket path"},
+		{"if::80", "", Endpoint{}, "", "missing interface name"},
+		{"if:en[:80", "", Endpoint{}, "", "invalid interface pattern"},
+		{"example.com", "", Endpoint{}, "", "missing port"},
+		{"::1", "", Endpoint{}, "", "missing port"},
+		{"[::1", "80", Endpoint{}, "", "missing ']'"},
+		{"[127.0.0.1]:80", "", Endpoint{}, "", "is not an IPv6 address"},
+		{"[::1]80", "", Endpoint{}, "", "unexpected '80' after ']'"},
+		{"fe80::zz:80", "", Endpoint{}, "", "must be written [address]:port"},
+		{"exa mple.com:80", "", Endpoint{}, "", "invalid character ' '"},
+		{"-example.com:80", "", Endpoint{}, "", "invalid host name"},
+		{"example.com:65536", "", Endpoint{}, "", "invalid port '65536'"},
+		{"example.com:http", "", Endpoint{}, "", "invalid port 'http'"},
+		{"[::1]:-1", "", Endpoint{}, "", "invalid port '-1'"},
+		{"if:eth0:x", "", Endpoint{}, "", "invalid port 'x'"},
+	}
+	for _, test := range tests {
+		ep, err := Parse(test.s, test.port)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Parse(%q) error %v, want %q", test.s, err,
+					test.err)
+			}
+		case err != nil:
+			t.Errorf("Parse(%q): %v", test.s, err)
+		case ep != test.ep:
+			t.Errorf("Parse(%q) = %+v, want %+v", test.s, ep, test.ep)
+		case ep.String() != test.str:
+			t.Errorf("Parse(%q).String() = %q, want %q", test.s,
+				ep.String(), test.str)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	ep := Endpoint{Network: "tcp", Host: "::1", Port: "80"}
+	if eps, err := ep.Expand(); err != nil || len(eps) != 1 || eps[0] != ep {
+		t.Fatal(eps, err)
+	}
+	ep = Endpoint{Network: "tcp", Interface: "no-such-interface?", Port: "80"}
+	if _, err := ep.Expand(); err == nil ||
+		!strings.Contains(err.Error(), "no network interface matches") {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,8 @@
+package endpoint
+
+import (
+	"github.com/cybriq/proc"
+	log2 "github.com/cybriq/proc/pkg/log"
+)
+
+var log = log2.GetLogger(proc.PathBase)
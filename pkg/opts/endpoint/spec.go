@@ -0,0 +1,134 @@
+package endpoint
+
+import (
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+)
+
+// Opt is an option holding a network endpoint, or with Multi, any number of
+// them. See Parse for the forms an endpoint can be written in.
+type Opt struct {
+	*opts.Typed[[]Endpoint]
+	port  string
+	multi bool
+	h     []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Endpoint), o.parse, format,
+		func(c *config.Concrete, v func() []Endpoint) {
+			c.Text = func() string {
+				if ep, ok := o.Endpoint(); ok {
+					return ep.String()
+				}
+				return ""
+			}
+			c.List = func() []string { return strs(v()) }
+		})
+	o.SetHooks(o.RunHooks)
+	_ = o.FromString(m.Default)
+	return
+}
+
+// Multi allows any number of endpoints, separated by commas.
+func (o *Opt) Multi() *Opt {
+	o.multi = true
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
+	}
+	return o
+}
+
+// DefaultPort sets the port used for endpoints given without one.
+func (o *Opt) DefaultPort(port string) *Opt {
+	o.port = port
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
+	}
+	return o
+}
+
+func (o *Opt) ToOption() config.Option { return o }
+
+// IsMulti returns true if more than one endpoint can be given.
+func (o *Opt) IsMulti() bool { return o.multi }
+
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
+}
+
+func (o *Opt) FromValue(v ...Endpoint) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
+}
+
+// parse reads the endpoints of a value, removing duplicates. An empty string
+// is no endpoints.
+func (o *Opt) parse(s string) (v []Endpoint, e error) {
+	v = []Endpoint{}
+	if s == "" {
+		return
+	}
+	items := []string{s}
+	if o.multi {
+		items = strings.Split(s, ",")
+	}
+	seen := make(map[Endpoint]bool)
+	for _, item := range items {
+		var ep Endpoint
+		if ep, e = Parse(item, o.port); e != nil {
+			return nil, e
+		}
+		if !seen[ep] {
+			seen[ep] = true
+			v = append(v, ep)
+		}
+	}
+	return
+}
+
+func format(v []Endpoint) string {
+	return strings.Join(strs(v), ",")
+}
+
+func strs(v []Endpoint) (s []string) {
+	s = make([]string, len(v))
+	for i := range v {
+		s[i] = v[i].String()
+	}
+	return
+}
+
+// Endpoint returns the first endpoint, and false if there is none.
+func (o *Opt) Endpoint() (ep Endpoint, ok bool) {
+	if v := o.Load(); len(v) > 0 {
+		return v[0], true
+	}
+	return
+}
+
+// Endpoints returns all the endpoints as they were given.
+func (o *Opt) Endpoints() []Endpoint {
+	return append([]Endpoint{}, o.Load()...)
+}
+
+// Expand returns the endpoints with the interfaces they name replaced by the
+// addresses of the interfaces.
+func (o *Opt) Expand() (eps []Endpoint, e error) {
+	for _, ep := range o.Load() {
+		var x []Endpoint
+		if x, e = ep.Expand(); e != nil {
+			return nil, e
+		}
+		eps = append(eps, x...)
+	}
+	return
+}
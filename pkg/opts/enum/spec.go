@@ -0,0 +1,129 @@
+package enum
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/util"
+)
+
+// Opt is an option whose value is one of the choices in the Options of its
+// metadata, or with Multi, any number of them. Matching ignores case, and
+// other names can be given for the choices with Alias.
+type Opt struct {
+	*opts.Typed[[]string]
+	a     map[string]string
+	multi bool
+	h     []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	o = &Opt{a: make(map[string]string), h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Enum), o.parse,
+		func(v []string) string { return strings.Join(v, ",") },
+		func(c *config.Concrete, v func() []string) {
+			c.Text = func() string {
+				if v := v(); len(v) > 0 {
+					return v[0]
+				}
+				return ""
+			}
+			c.List = v
+		})
+	o.SetHooks(o.RunHooks)
+	_ = o.FromString(m.Default)
+	return
+}
+
+// Multi allows any number of the choices to be selected, given separated by
+// commas.
+func (o *Opt) Multi() *Opt {
+	o.multi = true
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
+	}
+	return o
+}
+
+// Alias adds another name for a choice.
+func (o *Opt) Alias(alias, choice string) *Opt {
+	o.a[util.Norm(alias)] = choice
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
+	}
+	return o
+}
+
+func (o *Opt) ToOption() config.Option { return o }
+
+// IsMulti returns true if more than one choice can be selected.
+func (o *Opt) IsMulti() bool { return o.multi }
+
+// Choices returns the valid values.
+func (o *Opt) Choices() []string { return o.Meta().Options() }
+
+// Aliases returns the other names of the choices, with the choice each stands
+// for.
+func (o *Opt) Aliases() (a map[string]string) {
+	a = make(map[string]string, len(o.a))
+	for i := range o.a {
+		a[i] = o.a[i]
+	}
+	return
+}
+
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
+}
+
+func (o *Opt) FromValue(v ...string) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
+}
+
+// parse matches a value against the choices and their aliases, returning the
+// choices it selects as they are spelled in the metadata. An empty value
+// selects nothing.
+func (o *Opt) parse(s string) (v []string, e error) {
+	if s == "" {
+		return []string{}, nil
+	}
+	items := []string{s}
+	if o.multi {
+		items = strings.Split(s, ",")
+	}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		var choice string
+		if choice, e = o.match(strings.TrimSpace(item)); e != nil {
+			return nil, e
+		}
+		if !seen[choice] {
+			seen[choice] = true
+			v = append(v, choice)
+		}
+	}
+	return
+}
+
+// match finds the choice a value names.
+func (o *Opt) match(s string) (string, error) {
+	if c, ok := o.a[util.Norm(s)]; ok {
+		s = c
+	}
+	choices := o.Meta().Options()
+	for i := range choices {
+		if util.Norm(choices[i]) == util.Norm(s) {
+			return choices[i], nil
+		}
+	}
+	return "", fmt.Errorf("invalid value '%s', valid choices are: %s",
+		s, strings.Join(choices, ", "))
+}
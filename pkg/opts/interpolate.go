@@ -0,0 +1,61 @@
+package opts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Interpolator expands the ${name} references in a value.
+type Interpolator func(s string) (string, error)
+
+// Interpolated is an option whose value can contain ${name} references that
+// are expanded by an Interpolator.
+type Interpolated interface {
+	SetInterpolator(i Interpolator)
+}
+
+// References returns the names of the ${name} references in a string, in the
+// order they appear.
+func References(s string) (refs []string) {
+	_, _ = Interpolate(s, func(name string) (string, error) {
+		refs = append(refs, name)
+		return "", nil
+	})
+	return
+}
+
+// Interpolate replaces every ${name} in a string with the value returned by
+// lookup for the name. A literal ${ is written as $${.
+func Interpolate(s string, lookup func(name string) (string, error)) (
+	o string, err error) {
+
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += 2
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference in '%s'", s)
+			}
+			name := strings.TrimSpace(s[i+2 : i+end])
+			if name == "" {
+				return "", fmt.Errorf("empty reference in '%s'", s)
+			}
+			var v string
+			if v, err = lookup(name); err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i += end
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,83 @@
+package ip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+)
+
+// Opt is an option holding an IPv4 or IPv6 address. An empty value is no
+// address.
+type Opt struct {
+	*opts.Typed[net.IP]
+	h []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.IP), Parse, format,
+		func(c *config.Concrete, v func() net.IP) {
+			c.Text = func() string { return format(v()) }
+		})
+	o.SetHooks(o.RunHooks)
+	_ = o.FromString(m.Default)
+	return
+}
+
+func (o *Opt) ToOption() config.Option { return o }
+
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
+}
+
+func (o *Opt) FromValue(v net.IP) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
+}
+
+// Parse reads an IP address. An empty string is no address.
+func Parse(s string) (ip net.IP, e error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if ip = net.ParseIP(s); ip != nil {
+		return
+	}
+	switch {
+	case strings.Contains(s, "/"):
+		e = fmt.Errorf("'%s' is a network, not an IP address", s)
+	case strings.Contains(s, "%"):
+		e = fmt.Errorf("'%s' has a zone, which an IP address option "+
+			"cannot hold", s)
+	case strings.HasPrefix(s, "[") || strings.Count(s, ":") == 1:
+		e = fmt.Errorf("'%s' has a port, expected only an IP address", s)
+	default:
+		e = fmt.Errorf("'%s' is not an IPv4 or IPv6 address", s)
+	}
+	return
+}
+
+func format(v net.IP) string {
+	if v != nil {
+		return v.String()
+	}
+	return ""
+}
+
+// IP returns the address, which is nil if there is none.
+func (o *Opt) IP() net.IP {
+	v := o.Load()
+	if v == nil {
+		return nil
+	}
+	return append(net.IP{}, v...)
+}
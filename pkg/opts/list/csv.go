@@ -0,0 +1,86 @@
+package list
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Split reads the items of a list separated by sep. Space around an item is
+// removed, so items can be written after a separator and a space. As in CSV,
+// an item can be quoted with double quotes to hold the separator, quotes,
+// which are written twice, or surrounding space. An empty string is no items.
+func Split(s string, sep rune) (items []string, e error) {
+	items = []string{}
+	if s == "" {
+		return
+	}
+	var item strings.Builder
+	r := []rune(s)
+	space := func(i int) bool {
+		return i < len(r) && r[i] != sep && unicode.IsSpace(r[i])
+	}
+	for i := 0; i <= len(r); i++ {
+		for item.Len() == 0 && space(i) {
+			i++
+		}
+		if i < len(r) && r[i] == '"' && item.Len() == 0 {
+			// quoted item, which must end at a separator or the end
+			start := i
+			for i++; ; i++ {
+				if i >= len(r) {
+					return nil, fmt.Errorf("unterminated quote in item "+
+						"starting at %d of '%s'", start+1, s)
+				}
+				if r[i] == '"' {
+					if i+1 < len(r) && r[i+1] == '"' {
+						item.WriteRune('"')
+						i++
+						continue
+					}
+					break
+				}
+				item.WriteRune(r[i])
+			}
+			end := i + 1
+			for i++; space(i); i++ {
+			}
+			if i < len(r) && r[i] != sep {
+				return nil, fmt.Errorf("'%c' after the quoted item ending "+
+					"at %d of '%s', expected '%c'", r[i], end, s, sep)
+			}
+			items = append(items, item.String())
+			item.Reset()
+			continue
+		}
+		switch {
+		case i == len(r) || r[i] == sep:
+			items = append(items, strings.TrimRightFunc(item.String(),
+				unicode.IsSpace))
+			item.Reset()
+		case r[i] == '"':
+			return nil, fmt.Errorf("quote inside the unquoted item at %d "+
+				"of '%s', quote the item and write the quote twice", i+1, s)
+		default:
+			item.WriteRune(r[i])
+		}
+	}
+	return
+}
+
+// Join writes items separated by sep so Split reads them back the same,
+// quoting the items that are empty or contain the separator, quotes or
+// surrounding space.
+func Join(items []string, sep rune) string {
+	s := make([]string, len(items))
+	for i, item := range items {
+		s[i] = item
+		if item == "" || strings.ContainsRune(item, sep) ||
+			strings.ContainsRune(item, '"') ||
+			strings.TrimSpace(item) != item {
+
+			s[i] = `"` + strings.ReplaceAll(item, `"`, `""`) + `"`
+		}
+	}
+	return strings.Join(s, string(sep))
+}
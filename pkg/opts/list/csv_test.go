@@ -0,0 +1,71 @@
+package list
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		s     string
+		sep   rune
+		items []string
+		err   string
+	}{
+		{"", ',', []string{}, ""},
+		{"a", ',', []string{"a"}, ""},
+		{"a,b,c", ',', []string{"a", "b", "c"}, ""},
+		{"a, b , c", ',', []string{"a", "b", "c"}, ""},
+		{"a,,b", ',', []string{"a", "", "b"}, ""},
+		{"a,", ',', []string{"a", ""}, ""},
+		{"  ", ',', []string{""}, ""},
+		{"a, \"b,c\"", ',', []string{"a", "b,c"}, ""},
+		{"\" a \" , b", ',', []string{" a ", "b"}, ""},
+		{"\"say \"\"hi\"\"\",x", ',', []string{"say \"hi\"", "x"}, ""},
+		{"\"\",a", ',', []string{"", "a"}, ""},
+		{"a b;c d", ';', []string{"a b", "c d"}, ""},
+		{"a\tb", '\t', []string{"a", "b"}, ""},
+		{"a, \"b", ',', nil, "unterminated quote in item starting at 4"},
+		{"\"a\" x,b", ',', nil, "'x' after the quoted item ending at 3"},
+		{"a\"b", ',', nil, "quote inside the unquoted item at 2"},
+	}
+	for _, test := range tests {
+		items, err := Split(test.s, test.sep)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("Split(%q): %v", test.s, err)
+		case test.err != "" && (err == nil ||
+			!strings.Contains(err.Error(), test.err)):
+
+			t.Errorf("Split(%q) error %v, want %q", test.s, err, test.err)
+		case !reflect.DeepEqual(items, test.items):
+			t.Errorf("Split(%q) = %q, want %q", test.s, items, test.items)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		items []string
+		sep   rune
+		s     string
+	}{
+		{[]string{}, ',', ""},
+		{[]string{"a", "b"}, ',', "a,b"},
+		{[]string{"a,b", "c"}, ',', "\"a,b\",c"},
+		{[]string{" a", ""}, ',', "\" a\",\"\""},
+		{[]string{"say \"hi\""}, ',', "\"say \"\"hi\"\"\""},
+		{[]string{"a,b", "c;d"}, ';', "a,b;\"c;d\""},
+	}
+	for _, test := range tests {
+		s := Join(test.items, test.sep)
+		if s != test.s {
+			t.Errorf("Join(%q) = %q, want %q", test.items, s, test.s)
+		}
+		items, err := Split(s, test.sep)
+		if err != nil || !reflect.DeepEqual(items, test.items) {
+			t.Errorf("Split(Join(%q)) = %q, %v", test.items, items, err)
+		}
+	}
+}
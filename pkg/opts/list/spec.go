@@ -1,85 +1,115 @@
 package list
 
 import (
-	"strings"
-
+	"github.com/cybriq/proc/pkg/opts"
 	"github.com/cybriq/proc/pkg/opts/config"
 	"github.com/cybriq/proc/pkg/opts/meta"
 	"github.com/cybriq/proc/pkg/opts/normalize"
-	"github.com/cybriq/proc/pkg/path"
 	"go.uber.org/atomic"
 )
 
+// Opt is an option holding a list of text items. As a string the items are
+// separated by commas, or the separator given with Separator, and quoted as
+// in CSV when they need to be, see Split.
 type Opt struct {
-	p path.Path
-	m meta.Metadata
-	v atomic.Value
-	x atomic.Value
-	h []Hook
+	*opts.Typed[[]string]
+	x   atomic.Value
+	f   opts.Interpolator
+	sep rune
+	h   []Hook
 }
 
 var _ config.Option = &Opt{}
 
-func (o *Opt) Path() (p path.Path) {
-	return o.p
-}
-
-func (o *Opt) SetPath(p path.Path) {
-	o.p = p
-}
-
 type Hook func(*Opt) error
 
 func New(m meta.Data, h ...Hook) (o *Opt) {
-	o = &Opt{m: meta.New(m, meta.List), h: h}
+	o = &Opt{sep: ',', h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.List),
+		func(s string) ([]string, error) { return Split(s, o.sep) },
+		func(v []string) string { return Join(v, o.sep) },
+		func(c *config.Concrete, v func() []string) { c.List = v })
+	o.ValidateWith(o.validate)
+	o.SetHooks(o.RunHooks)
 	_ = o.FromString(m.Default)
 	return
 }
 
-func (o *Opt) Meta() meta.Metadata     { return o.m }
-func (o *Opt) Type() meta.Type         { return o.m.Typ }
+// Separator sets the character between the items in place of a comma, such
+// as ':' for a list of paths like PATH.
+func (o *Opt) Separator(sep rune) *Opt {
+	o.sep = sep
+	_ = o.FromString(o.Meta().Default())
+	return o
+}
+
+// Sep returns the character between the items.
+func (o *Opt) Sep() rune { return o.sep }
+
 func (o *Opt) ToOption() config.Option { return o }
 
+// RunHooks expands the ${name} references in the items, storing the result as
+// the expanded value, and then runs the hooks.
 func (o *Opt) RunHooks() (e error) {
-	for i := range o.h {
-		e = o.h[i](o)
-		if e != nil {
-			return
-		}
+	var x []string
+	if x, e = o.interpolate(o.Load()); e != nil {
+		return
 	}
-	return
+	o.x.Store(x)
+	return opts.RunHooks(o, o.h)
 }
 
-func (o *Opt) FromValue(v []string) *Opt {
-	o.v.Store(v)
-	return o
+// SetInterpolator sets the function that expands the ${name} references in
+// the items.
+func (o *Opt) SetInterpolator(i opts.Interpolator) {
+	o.f = i
 }
 
-func (o *Opt) FromString(s string) (e error) {
-	s = strings.TrimSpace(s)
-	split := strings.Split(s, ",")
-	o.v.Store(split)
-	e = o.RunHooks()
+func (o *Opt) interpolate(v []string) (x []string, e error) {
+	x = make([]string, len(v))
+	for i := range v {
+		x[i] = v[i]
+		if o.f != nil {
+			if x[i], e = o.f(v[i]); e != nil {
+				return
+			}
+		}
+	}
 	return
 }
 
-func (o *Opt) String() (s string) {
-	return strings.Join(o.v.Load().([]string), ",")
+// validate checks the items with the validators of the option as the hooks
+// will see them, with references expanded.
+func (o *Opt) validate(v []string) ([]string, error) {
+	if len(o.Meta().Validators()) < 1 {
+		return v, nil
+	}
+	x, e := o.interpolate(v)
+	if e != nil {
+		return v, e
+	}
+	_, e = opts.Validate(o.Meta(), x)
+	return v, e
 }
 
-func (o *Opt) Expanded() (s string) {
-	return o.String()
+func (o *Opt) FromValue(v []string) *Opt {
+	if !log.E.Chk(o.Set(v)) {
+		o.x.Store(o.Load())
+	}
+	return o
 }
 
-func (o *Opt) SetExpanded(s string) {
-	err := o.FromString(s)
-	log.E.Chk(err)
+// Expanded returns the items with references expanded and the hooks applied,
+// written as the value is.
+func (o *Opt) Expanded() (s string) {
+	return Join(o.Items(), o.sep)
 }
 
-func (o *Opt) Value() (c config.Concrete) {
-	c = config.NewConcrete()
-	c.List = func() []string { return o.v.Load().([]string) }
-	return
+// Items returns the items with references expanded and the hooks applied.
+// The Command tree runs the hooks again when a value the option refers to
+// changes, so they follow that value.
+func (o *Opt) Items() []string {
+	return append([]string{}, o.x.Load().([]string)...)
 }
 
 // NormalizeNetworkAddress checks correctness of a network address
@@ -91,7 +121,7 @@ func NormalizeNetworkAddress(defaultPort string,
 	return func(o *Opt) (e error) {
 		var a []string
 		a, e = normalize.Addresses(
-			o.v.Load().([]string), defaultPort, userOnly)
+			o.x.Load().([]string), defaultPort, userOnly)
 		if !log.E.Chk(e) {
 			o.x.Store(a)
 		}
@@ -104,15 +134,16 @@ func NormalizeNetworkAddress(defaultPort string,
 // filesystem root
 func NormalizeFilesystemPath(abs bool, appName string) func(*Opt) error {
 	return func(o *Opt) (e error) {
-		strings := o.v.Load().([]string)
-		for i := range strings {
-			var cleaned string
-			cleaned, e = normalize.ResolvePath(strings[i], appName, abs)
-			if !log.E.Chk(e) {
-				strings[i] = cleaned
+		x := o.x.Load().([]string)
+		cleaned := make([]string, len(x))
+		for i := range x {
+			if cleaned[i], e = normalize.ResolvePath(x[i], appName,
+				abs); log.E.Chk(e) {
+
+				cleaned[i] = x[i]
 			}
 		}
-		o.x.Store(strings)
+		o.x.Store(cleaned)
 		return
 	}
 }
@@ -0,0 +1,180 @@
+package mapopt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/list"
+	"github.com/cybriq/proc/pkg/opts/meta"
+)
+
+// Opt is an option holding a map of keys to values. The values are text
+// unless another type is given with Of, in which case they are checked to
+// parse as that type.
+//
+// As a string the map is written as k1=v1,k2=v2, which is the form used in
+// the environment. An entry whose value holds a comma is quoted as a list item
+// is, such as "k1=v1, v2", see list.Split. On the command line each use of the
+// option adds entries with Set.
+type Opt struct {
+	*opts.Typed[map[string]string]
+	t meta.Type
+	h []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	o = &Opt{t: meta.Text, h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Map), o.parse, format,
+		func(c *config.Concrete, _ func() map[string]string) { c.Map = o.Map })
+	o.SetHooks(o.RunHooks)
+	_ = o.FromString(m.Default)
+	return
+}
+
+// Of sets the type of the values, which can be Bool, Duration, Float, Integer
+// or Text.
+func (o *Opt) Of(t meta.Type) *Opt {
+	o.t = t
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
+	}
+	return o
+}
+
+// ValueType returns the type of the values.
+func (o *Opt) ValueType() meta.Type { return o.t }
+
+func (o *Opt) ToOption() config.Option { return o }
+
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
+}
+
+func (o *Opt) FromValue(v map[string]string) *Opt {
+	log.E.Chk(o.Typed.Set(v))
+	return o
+}
+
+// CheckValue returns an error if a value does not parse as the type of the
+// values.
+func (o *Opt) CheckValue(key, value string) (e error) {
+	switch o.t {
+	case meta.Bool:
+		_, e = strconv.ParseBool(value)
+	case meta.Duration:
+		_, e = time.ParseDuration(value)
+	case meta.Float:
+		_, e = strconv.ParseFloat(value, 64)
+	case meta.Integer:
+		_, e = strconv.ParseInt(value, 10, 64)
+	}
+	if e != nil {
+		e = fmt.Errorf("value of '%s' is not %s: '%s'", key,
+			strings.ToLower(string(o.t)), value)
+	}
+	return
+}
+
+// parse reads a map written as k1=v1,k2=v2, with the entries quoted as list
+// items. An empty string is an empty map.
+func (o *Opt) parse(s string) (v map[string]string, e error) {
+	var items []string
+	if items, e = list.Split(s, ','); e != nil {
+		return
+	}
+	v = make(map[string]string, len(items))
+	for _, item := range items {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) < 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("expected key=value, found '%s'", item)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if e = o.CheckValue(key, value); e != nil {
+			return nil, e
+		}
+		v[key] = value
+	}
+	return
+}
+
+// format writes the map as k1=v1,k2=v2 with the keys in sorted order, so
+// parse reads it back the same.
+func format(v map[string]string) string {
+	k := keys(v)
+	items := make([]string, len(k))
+	for i := range k {
+		items[i] = k[i] + "=" + v[k[i]]
+	}
+	return list.Join(items, ',')
+}
+
+func keys(v map[string]string) (k []string) {
+	for i := range v {
+		k = append(k, i)
+	}
+	sort.Strings(k)
+	return
+}
+
+// Set adds the entries of a map written as k1=v1,k2=v2 to the map, replacing
+// the values of keys that are already set, and runs the hooks.
+func (o *Opt) Set(s string) (e error) {
+	var add map[string]string
+	if add, e = o.Parse(s); e != nil {
+		return
+	}
+	v := o.Map()
+	for k := range add {
+		v[k] = add[k]
+	}
+	if e = o.Typed.Set(v); e != nil {
+		return
+	}
+	return o.RunHooks()
+}
+
+// Map returns a copy of the map.
+func (o *Opt) Map() (v map[string]string) {
+	current := o.Load()
+	v = make(map[string]string, len(current))
+	for k := range current {
+		v[k] = current[k]
+	}
+	return
+}
+
+// Keys returns the keys of the map in sorted order.
+func (o *Opt) Keys() []string {
+	return keys(o.Load())
+}
+
+// Values returns the values converted to the type of the values, as bool,
+// time.Duration, float64, int64 or string.
+func (o *Opt) Values() (v map[string]interface{}) {
+	current := o.Load()
+	v = make(map[string]interface{}, len(current))
+	for k, s := range current {
+		switch o.t {
+		case meta.Bool:
+			v[k], _ = strconv.ParseBool(s)
+		case meta.Duration:
+			v[k], _ = time.ParseDuration(s)
+		case meta.Float:
+			v[k], _ = strconv.ParseFloat(s, 64)
+		case meta.Integer:
+			v[k], _ = strconv.ParseInt(s, 10, 64)
+		default:
+			v[k] = s
+		}
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package mapopt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cybriq/proc/pkg/opts/meta"
+)
+
+func TestFormatParse(t *testing.T) {
+	o := New(meta.Data{})
+	for _, m := range []map[string]string{
+		{},
+		{"a": "1"},
+		{"b": "2", "a": "1"},
+		{"Accept": "text/html, application/json", "X": "1"},
+		{"q": `say "hi"`, "eq": "a=b"},
+	} {
+		s := format(m)
+		v, err := o.parse(s)
+		if err != nil || !reflect.DeepEqual(v, m) {
+			t.Errorf("parse(format(%q)) = %q, %v from %q", m, v, err, s)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s   string
+		t   meta.Type
+		v   map[string]string
+		err string
+	}{
+		{"", meta.Text, map[string]string{}, ""},
+		{"a=1, b = 2", meta.Text, map[string]string{"a": "1", "b": "2"}, ""},
+		{`"a=1,2",b=3`, meta.Text, map[string]string{"a": "1,2", "b": "3"},
+			""},
+		{"a=x=y", meta.Text, map[string]string{"a": "x=y"}, ""},
+		{"a=1,2", meta.Text, nil, "expected key=value, found '2'"},
+		{"=1", meta.Text, nil, "expected key=value"},
+		{`"a=1`, meta.Text, nil, "unterminated quote"},
+		{"a=x", meta.Integer, nil, "value of 'a' is not integer"},
+		{"a=1s", meta.Duration, map[string]string{"a": "1s"}, ""},
+	}
+	for _, test := range tests {
+		o := New(meta.Data{}).Of(test.t)
+		v, err := o.parse(test.s)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parse(%q) error %v, want %q", test.s, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("parse(%q): %v", test.s, err)
+		case !reflect.DeepEqual(v, test.v):
+			t.Errorf("parse(%q) = %q, want %q", test.s, v, test.v)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	o := New(meta.Data{Default: "a=1"})
+	if err := o.Set(`"b=x, y"`); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Set("a=2"); err != nil {
+		t.Fatal(err)
+	}
+	if s := o.String(); s != `a=2,"b=x, y"` {
+		t.Fatal(s)
+	}
+	if err := o.FromString(o.String()); err != nil ||
+		o.Map()["b"] != "x, y" {
+		t.Fatal(o.Map(), err)
+	}
+}
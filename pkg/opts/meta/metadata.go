@@ -5,11 +5,19 @@ type Type string
 // Type has same name as string for neater comparisons.
 const (
 	Bool     Type = "Bool"
+	CIDR     Type = "CIDR"
 	Duration Type = "Duration"
+	Endpoint Type = "Endpoint"
+	Enum     Type = "Enum"
 	Float    Type = "Float"
+	IP       Type = "IP"
 	Integer  Type = "Integer"
 	List     Type = "List"
+	Map      Type = "Map"
+	Secret   Type = "Secret"
+	Size     Type = "Size"
 	Text     Type = "Text"
+	URL      Type = "URL"
 )
 
 // Data is the specification for a Metadata
@@ -17,10 +25,15 @@ type Data struct {
 	Aliases       []string
 	Tags          []string
 	Label         string
+	Group         string // help section, the first of Tags if empty
+	Order         int    // place in the help section, if not 0
 	Description   string
 	Documentation string
 	Default       string
 	Options       []string
+	Env           string   // environment variable name replacing the default
+	EnvAliases    []string // further environment variables that are read
+	Validators    []Validator
 }
 
 // Metadata is a set of accessor functions that never write to the store and
@@ -29,10 +42,15 @@ type Metadata struct {
 	Aliases       func() []string
 	Tags          func() []string
 	Label         func() string
+	Group         func() string
+	Order         func() int
 	Description   func() string
 	Documentation func() string
 	Default       func() string
 	Options       func() []string
+	Env           func() string
+	EnvAliases    func() []string
+	Validators    func() []Validator
 	Typ           Type
 }
 
@@ -42,10 +60,15 @@ func New(d Data, t Type) Metadata {
 		func() []string { return d.Aliases },
 		func() []string { return d.Tags },
 		func() string { return d.Label },
+		func() string { return d.Group },
+		func() int { return d.Order },
 		func() string { return d.Description },
 		func() string { return d.Documentation },
 		func() string { return d.Default },
 		func() []string { return d.Options },
+		func() string { return d.Env },
+		func() []string { return d.EnvAliases },
+		func() []Validator { return d.Validators },
 		t,
 	}
 }
@@ -0,0 +1,406 @@
+package meta
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validator checks the value of an option before it is stored and before the
+// hooks run. A failing value is rejected with an error, or if the Validator
+// is Clamped and can fix it, replaced with the nearest valid value and a
+// warning is printed.
+//
+// Values are the typed values of the options: bool, int64, float64,
+// time.Duration, string, or a slice or map of them. Validators of single
+// values check every item of a slice. Text, list and secret values are
+// checked with their references resolved and expanded, as the hooks see them.
+type Validator struct {
+	// Description is shown in help, such as "at least 1".
+	Description string
+	// Schema holds the JSON Schema keywords the Validator corresponds to, if
+	// any.
+	Schema map[string]interface{}
+	// Check returns an error if a value is not valid.
+	Check func(v interface{}) error
+	// Fix returns the nearest valid value, if the Validator can clamp.
+	Fix func(v interface{}) interface{}
+	// Clamp makes Fix replace failing values instead of rejecting them.
+	Clamp bool
+}
+
+// Clamped returns the Validator changed to replace failing values with the
+// nearest valid one, with a warning, instead of rejecting them. Only
+// validators with a Fix, such as Min, Max and Range, can clamp.
+func (v Validator) Clamped() Validator {
+	v.Clamp = v.Fix != nil
+	return v
+}
+
+// Validators returns a list of validators, for meta.Data.
+func Validators(v ...Validator) []Validator { return v }
+
+// Validate checks a value with the validators of an option, returning the
+// value to store, which is changed if a clamping validator fixed it.
+func (m Metadata) Validate(v interface{}) (out interface{}, err error) {
+	out = v
+	for _, val := range m.Validators() {
+		if err = val.Check(out); err == nil {
+			continue
+		}
+		if !val.Clamp {
+			return v, err
+		}
+		fixed := val.Fix(out)
+		if e := val.Check(fixed); e != nil {
+			// the Validator could not fix the value
+			return v, err
+		}
+		log.W.F("%v, using %v", err, fixed)
+		out, err = fixed, nil
+	}
+	return
+}
+
+// Check returns the first error of a validator that would reject a value,
+// without changing it. Values clamping validators can fix are accepted.
+func (m Metadata) Check(v interface{}) error {
+	for _, val := range m.Validators() {
+		if err := val.Check(v); err != nil {
+			if !val.Clamp {
+				return err
+			}
+			if v = val.Fix(v); val.Check(v) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Describe returns the descriptions of the validators of an option.
+func (m Metadata) Describe() (d []string) {
+	for _, val := range m.Validators() {
+		d = append(d, val.Description)
+	}
+	return
+}
+
+// Custom makes a Validator from a function, with a description for help.
+func Custom(description string, check func(v interface{}) error) Validator {
+	return Validator{Description: description, Check: check}
+}
+
+// Min requires a number or duration of at least min.
+func Min(min interface{}) Validator {
+	return Range(min, nil)
+}
+
+// Max requires a number or duration of at most max.
+func Max(max interface{}) Validator {
+	return Range(nil, max)
+}
+
+// Range requires a number or duration from min to max. A nil min or max is
+// no limit.
+func Range(min, max interface{}) (v Validator) {
+	lo, hasLo := number(min)
+	hi, hasHi := number(max)
+	v.Schema = map[string]interface{}{}
+	switch {
+	case hasLo && hasHi:
+		v.Description = fmt.Sprintf("from %v to %v", min, max)
+	case hasLo:
+		v.Description = fmt.Sprintf("at least %v", min)
+	case hasHi:
+		v.Description = fmt.Sprintf("at most %v", max)
+	}
+	// durations are strings in the configuration, so have no schema limits
+	_, isDuration := min.(time.Duration)
+	if _, ok := max.(time.Duration); ok {
+		isDuration = true
+	}
+	if hasLo && !isDuration {
+		v.Schema["minimum"] = min
+	}
+	if hasHi && !isDuration {
+		v.Schema["maximum"] = max
+	}
+	v.Check = func(value interface{}) error {
+		return each(value, func(item interface{}) error {
+			n, ok := number(item)
+			switch {
+			case !ok:
+				return fmt.Errorf("%v is not a number", item)
+			case hasLo && n < lo:
+				return fmt.Errorf("%v is below the minimum of %v", item, min)
+			case hasHi && n > hi:
+				return fmt.Errorf("%v is above the maximum of %v", item, max)
+			}
+			return nil
+		})
+	}
+	v.Fix = func(value interface{}) interface{} {
+		return fixEach(value, func(item interface{}) interface{} {
+			n, ok := number(item)
+			switch {
+			case !ok:
+			case hasLo && n < lo:
+				return like(item, lo)
+			case hasHi && n > hi:
+				return like(item, hi)
+			}
+			return item
+		})
+	}
+	return
+}
+
+// Length requires a text with from min to max characters, or a list or map
+// with from min to max items. A max of zero is no maximum.
+func Length(min, max int) (v Validator) {
+	v.Description = fmt.Sprintf("length at least %d", min)
+	v.Schema = map[string]interface{}{"minLength": min, "minItems": min}
+	if max > 0 {
+		v.Description = fmt.Sprintf("length from %d to %d", min, max)
+		v.Schema["maxLength"], v.Schema["maxItems"] = max, max
+	}
+	v.Check = func(value interface{}) error {
+		var n int
+		if s, ok := value.(string); ok {
+			n = utf8.RuneCountInString(s)
+		} else {
+			rv := reflect.ValueOf(value)
+			switch rv.Kind() {
+			case reflect.Slice, reflect.Map:
+				n = rv.Len()
+			default:
+				return fmt.Errorf("%v has no length", value)
+			}
+		}
+		switch {
+		case n < min:
+			return fmt.Errorf("length %d is less than %d", n, min)
+		case max > 0 && n > max:
+			return fmt.Errorf("length %d is more than %d", n, max)
+		}
+		return nil
+	}
+	return
+}
+
+// Regex requires text matching a regular expression, which must compile.
+func Regex(pattern string) Validator {
+	re := regexp.MustCompile(pattern)
+	return Validator{
+		Description: fmt.Sprintf("matching /%s/", pattern),
+		Schema:      map[string]interface{}{"pattern": pattern},
+		Check: func(value interface{}) error {
+			return each(value, func(item interface{}) error {
+				if !re.MatchString(fmt.Sprint(item)) {
+					return fmt.Errorf("'%v' does not match /%s/", item,
+						pattern)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// OneOf requires one of the given values, compared as text.
+func OneOf(values ...string) Validator {
+	return Validator{
+		Description: "one of: " + strings.Join(values, ", "),
+		Schema:      map[string]interface{}{"enum": values},
+		Check: func(value interface{}) error {
+			return each(value, func(item interface{}) error {
+				s := fmt.Sprint(item)
+				for i := range values {
+					if values[i] == s {
+						return nil
+					}
+				}
+				return fmt.Errorf("'%s' is not one of: %s", s,
+					strings.Join(values, ", "))
+			})
+		},
+	}
+}
+
+// PathExists requires the path to exist. Like the other path validators it
+// accepts an empty path, which is no path.
+func PathExists() Validator {
+	return Validator{
+		Description: "an existing path",
+		Check: func(value interface{}) error {
+			return each(value, func(item interface{}) error {
+				p := fmt.Sprint(item)
+				if p == "" {
+					return nil
+				}
+				if _, e := os.Stat(p); e != nil {
+					return fmt.Errorf("path '%s' does not exist", p)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// IsDir requires the path to be an existing directory.
+func IsDir() Validator {
+	return Validator{
+		Description: "an existing directory",
+		Check: func(value interface{}) error {
+			return each(value, func(item interface{}) error {
+				if p := fmt.Sprint(item); p != "" {
+					return isDir(p)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func isDir(p string) error {
+	fi, e := os.Stat(p)
+	if e != nil {
+		return fmt.Errorf("directory '%s' does not exist", p)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", p)
+	}
+	return nil
+}
+
+// WritableDir requires the path to be a directory that files can be created
+// in.
+func WritableDir() Validator {
+	return Validator{
+		Description: "a writable directory",
+		Check: func(value interface{}) error {
+			return each(value, func(item interface{}) error {
+				p := fmt.Sprint(item)
+				if p == "" {
+					return nil
+				}
+				if e := isDir(p); e != nil {
+					return e
+				}
+				f, e := os.CreateTemp(p, ".write-test-*")
+				if e != nil {
+					return fmt.Errorf("directory '%s' is not writable", p)
+				}
+				_ = f.Close()
+				return os.Remove(f.Name())
+			})
+		},
+	}
+}
+
+// PortAvailable requires a TCP port, or an address with a port, that can be
+// listened on. Values with an Address method, such as endpoints, are checked
+// by their address.
+func PortAvailable() Validator {
+	return Validator{
+		Description: "a free TCP port",
+		Check: func(value interface{}) error {
+			return each(value, func(item interface{}) error {
+				var addr string
+				switch v := item.(type) {
+				case interface{ Address() string }:
+					addr = v.Address()
+				case string:
+					if addr = v; addr == "" {
+						return nil
+					}
+				default:
+					n, ok := number(item)
+					if !ok {
+						return fmt.Errorf("%v is not a port", item)
+					}
+					addr = ":" + strconv.FormatInt(int64(n), 10)
+				}
+				l, e := net.Listen("tcp", addr)
+				if e != nil {
+					return fmt.Errorf("cannot listen on '%s': %w", addr, e)
+				}
+				return l.Close()
+			})
+		},
+	}
+}
+
+// each runs a check on a value, or on each item if it is a slice. Slices that
+// print as one value, such as net.IP, are a single value.
+func each(value interface{}, check func(item interface{}) error) error {
+	rv := reflect.ValueOf(value)
+	if _, ok := value.(fmt.Stringer); ok || rv.Kind() != reflect.Slice {
+		return check(value)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if e := check(rv.Index(i).Interface()); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// fixEach runs a fix on a value, or on each item if it is a slice, as each
+// checks them, returning a new slice of the fixed items.
+func fixEach(value interface{},
+	fix func(item interface{}) interface{}) interface{} {
+
+	rv := reflect.ValueOf(value)
+	if _, ok := value.(fmt.Stringer); ok || rv.Kind() != reflect.Slice {
+		return fix(value)
+	}
+	out := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		out.Index(i).Set(reflect.ValueOf(fix(rv.Index(i).Interface())))
+	}
+	return out.Interface()
+}
+
+// number converts a number or duration to a float64 for comparison.
+func number(v interface{}) (f float64, ok bool) {
+	if v == nil {
+		return
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return
+}
+
+// like converts a limit to the type of a value, so a clamped value has the
+// type of the option.
+func like(value interface{}, f float64) interface{} {
+	rv := reflect.ValueOf(value)
+	out := reflect.New(rv.Type()).Elem()
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		out.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		out.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		out.SetFloat(f)
+	}
+	return out.Interface()
+}
@@ -1,17 +1,36 @@
 package normalize
 
 import (
+	"errors"
 	"net"
 	"strconv"
+	"strings"
 )
 
 // Address returns addr with the passed default port appended if there is not
-// already a port specified.
+// already a port specified, which an IPv6 address written without brackets
+// cannot have. Other errors in the address are returned with the address
+// unchanged.
 func Address(addr, defaultPort string, userOnly bool) (a string, e error) {
 	var p string
 	a, p, e = net.SplitHostPort(addr)
-	if log.E.Chk(e) || p == "" {
-		return net.JoinHostPort(a, defaultPort), e
+	if e != nil {
+		host := addr
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+		ip, _, _ := strings.Cut(addr, "%")
+		var ae *net.AddrError
+		switch {
+		case errors.As(e, &ae) && ae.Err == "missing port in address":
+		case net.ParseIP(ip) != nil:
+		default:
+			return addr, e
+		}
+		return net.JoinHostPort(host, defaultPort), nil
+	}
+	if p == "" {
+		return net.JoinHostPort(a, defaultPort), nil
 	}
 	if userOnly {
 		p = ClampPortRange(p, defaultPort, 1024, 65535)
@@ -22,14 +41,18 @@ func Address(addr, defaultPort string, userOnly bool) (a string, e error) {
 }
 
 // Addresses returns a new slice with all the passed peer addresses normalized
-// with the given default port, and all duplicates removed.
+// with the given default port, and all duplicates removed. The first invalid
+// address is returned as an error.
 func Addresses(addrs []string, defaultPort string, userOnly bool) (a []string,
 	e error) {
 
+	a = make([]string, len(addrs))
 	for i := range addrs {
-		addrs[i], e = Address(addrs[i], defaultPort, userOnly)
+		if a[i], e = Address(addrs[i], defaultPort, userOnly); e != nil {
+			return nil, e
+		}
 	}
-	a = RemoveDuplicateAddresses(addrs)
+	a = RemoveDuplicateAddresses(a)
 	return
 }
 
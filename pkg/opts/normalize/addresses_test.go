@@ -0,0 +1,53 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		addr, port string
+		userOnly   bool
+		a          string
+		err        bool
+	}{
+		{"127.0.0.1:8333", "11047", false, "127.0.0.1:8333", false},
+		{"127.0.0.1", "11047", false, "127.0.0.1:11047", false},
+		{"example.com", "11047", false, "example.com:11047", false},
+		{"example.com:", "11047", false, "example.com:11047", false},
+		{"[::1]:8333", "11047", false, "[::1]:8333", false},
+		{"[::1]", "11047", false, "[::1]:11047", false},
+		{"[fe80::1%eth0]:8333", "11047", false, "[fe80::1%eth0]:8333", false},
+		{"127.0.0.1:80", "11047", true, "127.0.0.1:1024", false},
+		{"127.0.0.1:80", "11047", false, "127.0.0.1:80", false},
+		{"127.0.0.1:0", "11047", false, "127.0.0.1:1", false},
+		{"127.0.0.1:70000", "11047", false, "127.0.0.1:65535", false},
+		{"127.0.0.1:http", "11047", false, "127.0.0.1:11047", false},
+		{"::1", "11047", false, "[::1]:11047", false},
+		{"fe80::1%eth0", "11047", false, "[fe80::1%eth0]:11047", false},
+		// errors other than a missing port leave the address unchanged
+		{"[::1", "11047", false, "[::1", true},
+		{"a:b:c", "11047", false, "a:b:c", true},
+	}
+	for _, test := range tests {
+		a, err := Address(test.addr, test.port, test.userOnly)
+		if (err != nil) != test.err || a != test.a {
+			t.Errorf("Address(%q, %q, %v) = %q, %v, want %q", test.addr,
+				test.port, test.userOnly, a, err, test.a)
+		}
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	a, err := Addresses([]string{"127.0.0.1", "127.0.0.1:11047", "[::1]"},
+		"11047", false)
+	if err != nil || !reflect.DeepEqual(a, []string{"127.0.0.1:11047",
+		"[::1]:11047"}) {
+		t.Fatal(a, err)
+	}
+	if a, err = Addresses([]string{"127.0.0.1", "[::1"}, "11047",
+		false); err == nil || a != nil {
+		t.Fatal(a, err)
+	}
+}
@@ -9,24 +9,24 @@ import (
 	"github.com/cybriq/proc/pkg/appdata"
 )
 
+// ResolvePath expands the ~ home folder shortcut and cleans the path. If abs
+// is set the absolute path from filesystem root is returned, otherwise a
+// relative path is taken to be relative to the application data directory.
 func ResolvePath(input, appName string, abs bool) (cleaned string, e error) {
-	if strings.HasPrefix(input, "~") {
+	switch {
+	case input == "":
+	case strings.HasPrefix(input, "~"):
 		homeDir := getHomeDir()
 		input = strings.Replace(input, "~", homeDir, 1)
 		cleaned = filepath.Clean(input)
-	} else {
-
-		if abs {
-			if cleaned, e = filepath.Abs(cleaned); log.E.Chk(e) {
-				return
-			}
-			// if the path is relative, either ./ or not starting with a / then
-			// we assume the path is relative to the app data directory
-		} else if !strings.HasPrefix(string(os.PathSeparator), cleaned) ||
-			strings.HasPrefix("."+string(os.PathSeparator), cleaned) {
-
-			cleaned = filepath.Join(appdata.Dir(appName, false), cleaned)
+	case abs:
+		if cleaned, e = filepath.Abs(input); log.E.Chk(e) {
+			return
 		}
+	case !filepath.IsAbs(input):
+		cleaned = filepath.Join(appdata.Dir(appName, false), input)
+	default:
+		cleaned = filepath.Clean(input)
 	}
 	return
 }
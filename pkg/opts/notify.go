@@ -0,0 +1,49 @@
+package opts
+
+import (
+	"go.uber.org/atomic"
+)
+
+// Notifier is called when the value of an option changes, with the values
+// before and after as the option prints them.
+type Notifier func(old, new string)
+
+// Notifying is an option that calls a Notifier when its value changes.
+type Notifying interface {
+	SetNotifier(n Notifier)
+}
+
+// Notify holds the Notifier of an option. Typed embeds it and watches the
+// value in Set, FromString and Reset, so option types built on Typed notify
+// without doing anything themselves.
+type Notify struct {
+	n      atomic.Value
+	redact atomic.String
+}
+
+// SetNotifier sets the function called when the value changes.
+func (n *Notify) SetNotifier(f Notifier) { n.n.Store(f) }
+
+// Redact makes the Notifier receive s in place of the values, for options
+// whose values must not be shown.
+func (n *Notify) Redact(s string) { n.redact.Store(s) }
+
+// Watch reads the value before a change, and returns the function to call
+// after it, which calls the Notifier if the value is different.
+func (n *Notify) Watch(value func() string) (changed func()) {
+	f, _ := n.n.Load().(Notifier)
+	if f == nil {
+		return func() {}
+	}
+	old := value()
+	return func() {
+		v := value()
+		if v == old {
+			return
+		}
+		if r := n.redact.Load(); r != "" {
+			old, v = r, r
+		}
+		f(old, v)
+	}
+}
@@ -0,0 +1,169 @@
+package opts
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider returns the value a reference points to. The reference is given
+// without the scheme prefix.
+type Provider func(ref string) (value string, err error)
+
+// CommandScheme is the scheme of the references CommandProvider resolves.
+// It is not registered unless the application asks for it, see
+// CommandProvider.
+const CommandScheme = "cmd"
+
+var (
+	providers = map[string]Provider{
+		"env":  EnvProvider,
+		"file": FileProvider,
+	}
+	providersMx sync.Mutex
+)
+
+// Register adds a Provider for references of the form 'scheme:reference',
+// replacing any Provider already registered for the scheme.
+func Register(scheme string, p Provider) {
+	providersMx.Lock()
+	defer providersMx.Unlock()
+	providers[strings.ToLower(scheme)] = p
+}
+
+// Schemes returns the sorted list of schemes that have a Provider.
+func Schemes() (s []string) {
+	providersMx.Lock()
+	defer providersMx.Unlock()
+	for i := range providers {
+		s = append(s, i)
+	}
+	sort.Strings(s)
+	return
+}
+
+// provider returns the Provider for the scheme a string starts with, and the
+// reference after the scheme.
+func provider(s string) (p Provider, ref string, ok bool) {
+	split := strings.SplitN(s, ":", 2)
+	if len(split) < 2 {
+		return
+	}
+	providersMx.Lock()
+	p, ok = providers[strings.ToLower(split[0])]
+	providersMx.Unlock()
+	return p, split[1], ok
+}
+
+// IsReference returns true if a string starts with the scheme of a registered
+// Provider, without resolving it.
+func IsReference(s string) (isRef bool) {
+	_, _, isRef = provider(s)
+	return
+}
+
+// IsCommand returns true if a string is a reference with CommandScheme,
+// whether or not CommandProvider is registered.
+func IsCommand(s string) bool {
+	scheme, _, ok := strings.Cut(s, ":")
+	return ok && strings.EqualFold(scheme, CommandScheme)
+}
+
+// Resolve returns the value of a reference, or the string itself if it does
+// not start with the scheme of a registered Provider.
+func Resolve(s string) (v string, isRef bool, err error) {
+	p, ref, ok := provider(s)
+	if !ok {
+		return s, false, nil
+	}
+	if v, err = p(ref); err != nil {
+		err = fmt.Errorf("resolving reference '%s': %w", s, err)
+	}
+	return v, true, err
+}
+
+// Resolution resolves the value of an option once each time it is given a
+// value, so a value that is checked and then stored is resolved once, and the
+// value checked is the value stored.
+type Resolution struct {
+	mx      sync.Mutex
+	s, v    string
+	checked bool
+}
+
+// Checked returns the value of a reference being checked, which is kept for
+// when it is stored.
+func (r *Resolution) Checked(s string) (v string, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.checked && r.s == s {
+		return r.v, nil
+	}
+	r.checked = false
+	if v, _, err = Resolve(s); err == nil {
+		r.s, r.v, r.checked = s, v, true
+	}
+	return
+}
+
+// Stored returns the value of a reference that is stored, which is only
+// resolved again if it was not the value checked.
+func (r *Resolution) Stored(s string) (v string, err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.checked && r.s == s {
+		r.checked = false
+		return r.v, nil
+	}
+	r.checked = false
+	v, _, err = Resolve(s)
+	return
+}
+
+// FileProvider returns the content of a file with trailing line breaks
+// removed.
+func FileProvider(ref string) (value string, err error) {
+	var b []byte
+	if b, err = os.ReadFile(ref); err != nil {
+		return
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// CommandProvider runs a command and returns its output with trailing line
+// breaks removed. The reference is split into arguments on whitespace.
+//
+// Anyone who can set the value of an option can run a command with it, so it
+// is not registered by default. An application that trusts every source of
+// its values, the command line, the environment and the configuration file,
+// enables it with Register(CommandScheme, CommandProvider). Values from
+// dotenv files, which may be in an untrusted working directory, cannot be
+// commands even then.
+func CommandProvider(ref string) (value string, err error) {
+	args := strings.Fields(ref)
+	if len(args) < 1 {
+		return "", fmt.Errorf("no command given")
+	}
+	var b []byte
+	if b, err = exec.Command(args[0], args[1:]...).Output(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err,
+				strings.TrimSpace(string(ee.Stderr)))
+		}
+		return
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// EnvProvider returns the value of an environment variable, which must be
+// set.
+func EnvProvider(ref string) (value string, err error) {
+	var exists bool
+	if value, exists = os.LookupEnv(ref); !exists {
+		err = fmt.Errorf("environment variable '%s' is not set", ref)
+	}
+	return
+}
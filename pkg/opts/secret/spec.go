@@ -0,0 +1,185 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"go.uber.org/atomic"
+)
+
+// Redacted is printed in place of the value of a secret anywhere it would
+// otherwise be shown.
+const Redacted = "********"
+
+// EnvPrefix is the scheme of a reference to an environment variable, as
+// written by the SaveReference SaveMode.
+const EnvPrefix = "env:"
+
+// SaveMode sets how the value of a secret is written when the configuration is
+// saved.
+type SaveMode int32
+
+const (
+	// SaveIfReference writes the value only if it is a reference to where
+	// the secret is kept, such as file:/run/secrets/password, and otherwise
+	// leaves the option out, so a secret is never written in plain text
+	// unless asked for. It is the default.
+	SaveIfReference SaveMode = iota
+	// SaveValue writes the value as it was given, in plain text.
+	SaveValue
+	// SaveReference writes a reference to the environment variable of the
+	// option in place of the value.
+	SaveReference
+	// SaveOmit leaves the option out of the saved configuration.
+	SaveOmit
+)
+
+// Opt is a text option whose value is never printed. String, Expanded and the
+// Concrete value all return Redacted, the value is only available from Secret.
+type Opt struct {
+	*opts.Typed[string]
+	d string
+	x atomic.String
+	r opts.Resolution
+	s atomic.Int32
+	h []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+// New creates a secret option. The default is kept out of the Metadata so it
+// is not shown in help or configuration file comments.
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	d := m.Default
+	if m.Default != "" {
+		m.Default = Redacted
+	}
+	o = &Opt{d: d, h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Secret),
+		func(s string) (string, error) { return s, nil },
+		func(v string) string { return v },
+		func(c *config.Concrete, _ func() string) { c.Text = o.String })
+	o.ValidateWith(o.validate)
+	o.SetHooks(o.RunHooks)
+	o.Redact(Redacted)
+	_ = o.FromString(d)
+	return
+}
+
+func (o *Opt) ToOption() config.Option { return o }
+
+// SaveAs sets the SaveMode used when writing the configuration.
+func (o *Opt) SaveAs(mode SaveMode) *Opt {
+	o.s.Store(int32(mode))
+	return o
+}
+
+// SaveMode returns the current SaveMode of the option.
+func (o *Opt) SaveMode() SaveMode {
+	return SaveMode(o.s.Load())
+}
+
+// SavesValue returns true if a value of the option is written as it is when
+// the configuration is saved.
+func (o *Opt) SavesValue(v string) bool {
+	switch o.SaveMode() {
+	case SaveValue:
+		return true
+	case SaveIfReference:
+		return opts.IsReference(v)
+	}
+	return false
+}
+
+// Reset returns the option to its default value.
+func (o *Opt) Reset() (e error) {
+	return o.FromString(o.d)
+}
+
+// Initial returns the value Reset would store, from the default that is kept
+// out of the metadata.
+func (o *Opt) Initial() (s string) {
+	s, e := o.Normalise(o.d)
+	log.E.Chk(e)
+	return
+}
+
+// RunHooks resolves the value if it is a reference and then runs the hooks.
+func (o *Opt) RunHooks() (e error) {
+	if e = o.resolve(); e != nil {
+		return
+	}
+	return opts.RunHooks(o, o.h)
+}
+
+// resolve stores the value with a reference resolved as the secret.
+func (o *Opt) resolve() (e error) {
+	var x string
+	if x, e = o.r.Stored(o.Load()); e != nil {
+		return
+	}
+	o.x.Store(x)
+	return
+}
+
+// FromValue sets the value and resolves it if it is a reference, as
+// FromString does, without running the hooks.
+func (o *Opt) FromValue(v string) *Opt {
+	if !log.E.Chk(o.Set(v)) {
+		log.E.Chk(o.resolve())
+	}
+	return o
+}
+
+// validate checks a value with the validators of the option once a reference
+// in it is resolved, without the value appearing in the error.
+func (o *Opt) validate(v string) (string, error) {
+	if len(o.Meta().Validators()) < 1 {
+		return v, nil
+	}
+	x, e := o.r.Checked(v)
+	if e != nil {
+		return v, e
+	}
+	if _, e = opts.Validate(o.Meta(), x); e != nil {
+		return v, Invalid(o.Meta())
+	}
+	return v, nil
+}
+
+// Invalid is the error for a secret value its validators reject, which only
+// describes the validators so the value is not shown.
+func Invalid(m meta.Metadata) error {
+	return fmt.Errorf("secret value is not valid, it must be %s",
+		strings.Join(m.Describe(), " and "))
+}
+
+func (o *Opt) String() (s string) {
+	if o.Load() == "" {
+		return ""
+	}
+	return Redacted
+}
+
+func (o *Opt) Expanded() (s string) {
+	return o.String()
+}
+
+func (o *Opt) SetExpanded(s string) {
+	o.x.Store(s)
+}
+
+// Secret returns the value of the secret with any reference resolved.
+func (o *Opt) Secret() (s string) {
+	return o.x.Load()
+}
+
+// Raw returns the value as it was given, which may be a reference.
+func (o *Opt) Raw() (s string) {
+	return o.Load()
+}
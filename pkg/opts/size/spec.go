@@ -0,0 +1,77 @@
+package size
+
+import (
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/opts/units"
+)
+
+// Opt is an option holding a number of bytes. It is written with SI or IEC
+// units, such as 64k, 1.5GB or 512MiB, and printed with the largest unit that
+// represents it exactly.
+type Opt struct {
+	*opts.Typed[int64]
+	h []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+// New creates a size option. The default is shown in help as it will be
+// printed, so 1048576 is shown as 1MiB. The size in bytes is the Integer of
+// the Concrete value.
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	if n, e := units.ParseSize(m.Default); e == nil {
+		m.Default = units.FormatSize(n)
+	}
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Size), units.ParseSize,
+		units.FormatSize,
+		func(c *config.Concrete, v func() int64) { c.Integer = v })
+	o.SetHooks(o.RunHooks)
+	_ = o.FromString(m.Default)
+	return
+}
+
+func (o *Opt) ToOption() config.Option { return o }
+
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
+}
+
+// FromValue sets the size in bytes.
+func (o *Opt) FromValue(v int64) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
+}
+
+// Bytes returns the size in bytes.
+func (o *Opt) Bytes() int64 { return o.Load() }
+
+// Clamp is a Hook that keeps the size between min and max, which are written
+// as sizes, such as 4KiB and 1GiB.
+func Clamp(min, max string) Hook {
+	lo, e := units.ParseSize(min)
+	if log.E.Chk(e) {
+		return func(*Opt) error { return e }
+	}
+	hi, e := units.ParseSize(max)
+	if log.E.Chk(e) {
+		return func(*Opt) error { return e }
+	}
+	return func(o *Opt) error {
+		v := o.Load()
+		if v < lo {
+			log.W.F("%s is below the minimum, using %s", units.FormatSize(v),
+				units.FormatSize(lo))
+			o.Store(lo)
+		} else if v > hi {
+			log.W.F("%s is above the maximum, using %s", units.FormatSize(v),
+				units.FormatSize(hi))
+			o.Store(hi)
+		}
+		return nil
+	}
+}
@@ -1,71 +1,97 @@
 package text
 
 import (
-	"strings"
-
+	"github.com/cybriq/proc/pkg/opts"
 	"github.com/cybriq/proc/pkg/opts/config"
 	"github.com/cybriq/proc/pkg/opts/meta"
 	"github.com/cybriq/proc/pkg/opts/normalize"
-	"github.com/cybriq/proc/pkg/path"
 	"go.uber.org/atomic"
 )
 
 type Opt struct {
-	p path.Path
-	m meta.Metadata
-	v atomic.String
+	*opts.Typed[string]
 	x atomic.String
+	r opts.Resolution
+	f opts.Interpolator
 	h []Hook
 }
 
-func (o *Opt) Path() (p path.Path) {
-	return o.p
-}
-
-func (o *Opt) SetPath(p path.Path) {
-	o.p = p
-}
-
 var _ config.Option = &Opt{}
 
 type Hook func(*Opt) error
 
 func New(m meta.Data, h ...Hook) (o *Opt) {
-	o = &Opt{m: meta.New(m, meta.Text), h: h}
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.Text),
+		func(s string) (string, error) { return s, nil },
+		func(v string) string { return v },
+		func(c *config.Concrete, v func() string) { c.Text = v })
+	o.ValidateWith(o.validate)
+	o.SetHooks(o.RunHooks)
 	_ = o.FromString(m.Default)
 	return
 }
 
-func (o *Opt) Meta() meta.Metadata     { return o.m }
-func (o *Opt) Type() meta.Type         { return o.m.Typ }
 func (o *Opt) ToOption() config.Option { return o }
 
+// RunHooks resolves the value if it is a reference and expands the ${name}
+// references in it, storing the result as the expanded value, and then runs
+// the hooks.
 func (o *Opt) RunHooks() (e error) {
-	for i := range o.h {
-		e = o.h[i](o)
-		if e != nil {
-			return
-		}
+	var x string
+	if x, e = o.expand(o.Load(), o.r.Stored); e != nil {
+		return
 	}
-	return
+	o.x.Store(x)
+	return opts.RunHooks(o, o.h)
 }
 
-func (o *Opt) FromValue(v string) *Opt {
-	o.v.Store(v)
-	return o
+// expand returns a value with a reference resolved and the ${name}
+// references in it expanded.
+func (o *Opt) expand(v string,
+	resolve func(s string) (string, error)) (x string, e error) {
+
+	if x, e = resolve(v); e != nil {
+		return
+	}
+	return o.interpolate(x)
 }
 
-func (o *Opt) FromString(s string) (e error) {
-	s = strings.TrimSpace(s)
-	o.v.Store(s)
-	e = o.RunHooks()
-	return
+// validate checks a value with the validators of the option as the hooks
+// will see it, with references resolved and expanded.
+func (o *Opt) validate(v string) (string, error) {
+	if len(o.Meta().Validators()) < 1 {
+		return v, nil
+	}
+	x, e := o.expand(v, o.r.Checked)
+	if e != nil {
+		return v, e
+	}
+	_, e = opts.Validate(o.Meta(), x)
+	return v, e
 }
 
-func (o *Opt) String() (s string) {
-	return o.v.Load()
+// SetInterpolator sets the function that expands the ${name} references in
+// the value.
+func (o *Opt) SetInterpolator(i opts.Interpolator) {
+	o.f = i
 }
 
+func (o *Opt) interpolate(s string) (string, error) {
+	if o.f == nil {
+		return s, nil
+	}
+	return o.f(s)
+}
+
+func (o *Opt) FromValue(v string) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
+}
+
+// Expanded returns the value with references resolved and the hooks applied.
+// The Command tree runs the hooks again when a value the option refers to
+// changes, so it follows that value.
 func (o *Opt) Expanded() (s string) {
 	return o.x.Load()
 }
@@ -74,12 +100,6 @@ func (o *Opt) SetExpanded(s string) {
 	o.x.Store(s)
 }
 
-func (o *Opt) Value() (c config.Concrete) {
-	c = config.NewConcrete()
-	c.Text = func() string { return o.v.Load() }
-	return
-}
-
 // NormalizeNetworkAddress checks correctness of a network address
 // specification, and adds a default path if needed, and enforces whether the
 // port requires root permission and clamps it if not.
@@ -88,7 +108,7 @@ func NormalizeNetworkAddress(defaultPort string,
 
 	return func(o *Opt) (e error) {
 		var a string
-		a, e = normalize.Address(o.v.Load(), defaultPort, userOnly)
+		a, e = normalize.Address(o.x.Load(), defaultPort, userOnly)
 		if !log.E.Chk(e) {
 			o.x.Store(a)
 		}
@@ -102,7 +122,7 @@ func NormalizeNetworkAddress(defaultPort string,
 func NormalizeFilesystemPath(abs bool, appName string) func(*Opt) error {
 	return func(o *Opt) (e error) {
 		var cleaned string
-		cleaned, e = normalize.ResolvePath(o.v.Load(), appName, abs)
+		cleaned, e = normalize.ResolvePath(o.x.Load(), appName, abs)
 		if !log.E.Chk(e) {
 			o.x.Store(cleaned)
 		}
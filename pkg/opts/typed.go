@@ -0,0 +1,237 @@
+package opts
+
+import (
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+	"github.com/cybriq/proc/pkg/path"
+	"go.uber.org/atomic"
+)
+
+// Parser reads a value of an option from its string form.
+type Parser[T any] func(s string) (v T, err error)
+
+// Formatter writes a value of an option in the form its Parser reads.
+type Formatter[T any] func(v T) (s string)
+
+// Valuer sets the accessor of a Concrete that returns the value of an option.
+type Valuer[T any] func(c *config.Concrete, v func() T)
+
+// Typed is an option holding a value of type T, which is read and written by
+// the Parser and Formatter it is made with. It implements config.Option, and
+// option types embed it, adding their own Hook type with SetHooks and any
+// accessors of their own.
+type Typed[T any] struct {
+	Notify
+	p      path.Path
+	m      meta.Metadata
+	v      atomic.Value
+	parse  Parser[T]
+	format Formatter[T]
+	value  Valuer[T]
+	valid  func(v T) (T, error)
+	hooks  func() error
+}
+
+var _ config.Option = &Typed[string]{}
+
+// NewTyped creates a Typed option with the zero value of T. The value is not
+// parsed from the default, so the caller can finish setting up the option
+// first.
+func NewTyped[T any](m meta.Metadata, parse Parser[T], format Formatter[T],
+	value Valuer[T]) (t *Typed[T]) {
+
+	t = &Typed[T]{m: m, parse: parse, format: format, value: value}
+	var zero T
+	t.v.Store(box[T]{zero})
+	return
+}
+
+// box holds a value in the atomic.Value, which cannot store nil interfaces
+// and needs the same concrete type in every Store.
+type box[T any] struct{ v T }
+
+// RunHooks runs the hooks of a list of any hook type of an option, stopping
+// at the first error.
+func RunHooks[O any, H ~func(O) error](o O, hooks []H) (e error) {
+	for i := range hooks {
+		if e = hooks[i](o); e != nil {
+			return
+		}
+	}
+	return
+}
+
+// SetHooks sets the function that RunHooks calls, which runs the hooks of the
+// option type embedding the Typed.
+func (t *Typed[T]) SetHooks(run func() error) { t.hooks = run }
+
+// ValidateWith sets the function that checks a value before it is stored, in
+// place of the validators of the metadata, for option types that must check
+// the value in another form or keep it out of the errors.
+func (t *Typed[T]) ValidateWith(f func(v T) (T, error)) { t.valid = f }
+
+func (t *Typed[T]) validate(v T) (T, error) {
+	if t.valid != nil {
+		return t.valid(v)
+	}
+	return Validate(t.m, v)
+}
+
+func (t *Typed[T]) Path() (p path.Path) { return t.p }
+func (t *Typed[T]) SetPath(p path.Path) { t.p = p }
+func (t *Typed[T]) Meta() meta.Metadata { return t.m }
+func (t *Typed[T]) Type() meta.Type     { return t.m.Typ }
+
+func (t *Typed[T]) RunHooks() (e error) {
+	if t.hooks != nil {
+		e = t.hooks()
+	}
+	return
+}
+
+// Load returns the value.
+func (t *Typed[T]) Load() T { return t.v.Load().(box[T]).v }
+
+// Store sets the value without validating it or running the hooks. Hooks
+// use it to change the value.
+func (t *Typed[T]) Store(v T) { t.v.Store(box[T]{v}) }
+
+// Set validates a value and stores it, without running the hooks. A value
+// the validators reject is not stored.
+func (t *Typed[T]) Set(v T) (e error) {
+	defer t.Watch(t.String)()
+	if v, e = t.validate(v); e != nil {
+		return
+	}
+	t.Store(v)
+	return
+}
+
+// Parse reads a value with the Parser of the option, with surrounding space
+// removed, without storing it.
+func (t *Typed[T]) Parse(s string) (v T, e error) {
+	return t.parse(strings.TrimSpace(s))
+}
+
+func (t *Typed[T]) FromString(s string) (e error) {
+	defer t.Watch(t.String)()
+	var v T
+	if v, e = t.Parse(s); e != nil {
+		return
+	}
+	if v, e = t.validate(v); e != nil {
+		return
+	}
+	t.Store(v)
+	e = t.RunHooks()
+	return
+}
+
+// Reset returns the option to its default value, and runs the hooks. An
+// empty default that does not parse, such as for a number, is the zero value,
+// as when the option is created.
+func (t *Typed[T]) Reset() (e error) {
+	if t.m.Default() != "" {
+		return t.FromString(t.m.Default())
+	}
+	defer t.Watch(t.String)()
+	var v T
+	if v, e = t.Parse(""); e != nil {
+		var zero T
+		v = zero
+	}
+	t.Store(v)
+	return t.RunHooks()
+}
+
+// Check returns the error FromString would give for a value, without storing
+// it or running the hooks.
+func (t *Typed[T]) Check(s string) (e error) {
+	var v T
+	if v, e = t.Parse(s); e != nil {
+		return
+	}
+	if t.valid != nil {
+		_, e = t.valid(v)
+		return
+	}
+	return t.m.Check(v)
+}
+
+// Normalise returns the value FromString would store for a string, in the
+// form String writes it, without storing it or running the hooks.
+func (t *Typed[T]) Normalise(s string) (n string, e error) {
+	var v T
+	if v, e = t.Parse(s); e != nil {
+		return
+	}
+	if v, e = t.validate(v); e != nil {
+		return
+	}
+	return t.format(v), nil
+}
+
+// Initial returns the value Reset would store, in the form String writes it.
+func (t *Typed[T]) Initial() (s string) {
+	if t.m.Default() != "" {
+		var e error
+		if s, e = t.Normalise(t.m.Default()); e == nil {
+			return
+		}
+	}
+	v, e := t.Parse("")
+	if e != nil {
+		var zero T
+		v = zero
+	}
+	return t.format(v)
+}
+
+func (t *Typed[T]) String() (s string) {
+	return t.format(t.Load())
+}
+
+func (t *Typed[T]) Expanded() (s string) {
+	return t.String()
+}
+
+func (t *Typed[T]) SetExpanded(s string) {
+	err := t.FromString(s)
+	log.E.Chk(err)
+}
+
+func (t *Typed[T]) Value() (c config.Concrete) {
+	c = config.NewConcrete()
+	if t.value != nil {
+		t.value(&c, t.Load)
+	}
+	return
+}
+
+// Checker is an option that can check a value would be accepted without
+// storing it.
+type Checker interface {
+	Check(s string) error
+}
+
+// Normaliser is an option that can give the value it would store for a
+// string, and for its default, without storing it.
+type Normaliser interface {
+	Normalise(s string) (string, error)
+	Initial() string
+}
+
+// Validate checks a value with the validators in the metadata of an option,
+// returning it, or the value a clamping validator replaced it with.
+func Validate[T any](m meta.Metadata, v T) (T, error) {
+	if len(m.Validators()) < 1 {
+		return v, nil
+	}
+	out, err := m.Validate(v)
+	if err != nil {
+		return v, err
+	}
+	return out.(T), nil
+}
@@ -0,0 +1,233 @@
+// Package units parses and formats numbers with unit suffixes, such as 64k,
+// 1.5GB and 512MiB.
+//
+// SI suffixes are powers of 1000 and IEC suffixes, with an 'i', are powers of
+// 1024. Numbers can also contain underscores between digits, and integers can
+// be written in hexadecimal, octal or binary with the 0x, 0o and 0b prefixes.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// unit is a suffix and its multiplier.
+type unit struct {
+	suffix string
+	mul    float64
+}
+
+// si are the SI multipliers, largest first. The small ones are only used for
+// floating point numbers.
+var si = []unit{
+	{"E", 1e18}, {"P", 1e15}, {"T", 1e12}, {"G", 1e9}, {"M", 1e6},
+	{"k", 1e3},
+}
+
+var siSmall = []unit{
+	{"m", 1e-3}, {"u", 1e-6}, {"µ", 1e-6}, {"n", 1e-9},
+}
+
+// iec are the IEC binary multipliers, largest first.
+var iec = []unit{
+	{"Ei", 1 << 60}, {"Pi", 1 << 50}, {"Ti", 1 << 40}, {"Gi", 1 << 30},
+	{"Mi", 1 << 20}, {"Ki", 1 << 10},
+}
+
+// split separates a number from its suffix, and removes underscores from the
+// number. Numbers with a base prefix have no suffix, though a zero followed
+// only by a b is a size of 0 bytes.
+func split(s string) (num, suffix string) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "_", "")
+	body := strings.ToLower(strings.TrimLeft(s, "+-"))
+	if len(body) > 2 && (strings.HasPrefix(body, "0x") ||
+		strings.HasPrefix(body, "0o") || strings.HasPrefix(body, "0b")) {
+
+		return s, ""
+	}
+	num = strings.TrimRightFunc(s, unicode.IsLetter)
+	return strings.TrimSpace(num), s[len(num):]
+}
+
+// multiplier finds the multiplier of a suffix. For sizes a trailing B for
+// bytes is allowed and case is ignored, as there are no fractional units.
+func multiplier(suffix string, small, bytes bool) (mul float64, err error) {
+	if suffix == "" {
+		return 1, nil
+	}
+	s := suffix
+	match := func(a, b string) bool { return a == b }
+	if bytes {
+		if strings.EqualFold(s, "B") {
+			return 1, nil
+		}
+		s = strings.TrimRight(s, "Bb")
+		match = strings.EqualFold
+	}
+	if s == "ki" || s == "KI" {
+		s = "Ki"
+	}
+	for _, u := range iec {
+		if match(u.suffix, s) {
+			return u.mul, nil
+		}
+	}
+	for _, u := range si {
+		if match(u.suffix, s) {
+			return u.mul, nil
+		}
+	}
+	if small {
+		for _, u := range siSmall {
+			if u.suffix == s {
+				return u.mul, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown unit '%s'", suffix)
+}
+
+// ParseInt parses an integer with an optional SI or IEC suffix. The number
+// before a suffix can have a fraction as long as the result is a whole
+// number.
+func ParseInt(s string) (n int64, err error) {
+	return parseInt(s, false)
+}
+
+// ParseSize parses a size in bytes, such as 512MiB, 1.5GB or 64k.
+func ParseSize(s string) (n int64, err error) {
+	return parseInt(s, true)
+}
+
+func parseInt(s string, bytes bool) (n int64, err error) {
+	num, suffix := split(s)
+	if num == "" {
+		return 0, fmt.Errorf("no number in '%s'", s)
+	}
+	var mul float64
+	if mul, err = multiplier(suffix, false, bytes); err != nil {
+		return 0, fmt.Errorf("'%s': %w", s, err)
+	}
+	if n, err = strconv.ParseInt(num, 0, 64); err == nil {
+		if mul == 1 {
+			return
+		}
+		if n > math.MaxInt64/int64(mul) || n < math.MinInt64/int64(mul) {
+			return 0, fmt.Errorf("'%s' is out of range", s)
+		}
+		return n * int64(mul), nil
+	}
+	var f float64
+	if f, err = strconv.ParseFloat(num, 64); err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", s)
+	}
+	f *= mul
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("'%s' is not a whole number", s)
+	}
+	if f >= math.MaxInt64 || f < math.MinInt64 {
+		return 0, fmt.Errorf("'%s' is out of range", s)
+	}
+	return int64(f), nil
+}
+
+// ParseFloat parses a number with an optional SI or IEC suffix, including the
+// SI fractions m, u and n.
+func ParseFloat(s string) (f float64, err error) {
+	num, suffix := split(s)
+	var mul float64
+	if mul, err = multiplier(suffix, true, false); err != nil {
+		return 0, fmt.Errorf("'%s': %w", s, err)
+	}
+	if f, err = strconv.ParseFloat(num, 64); err != nil {
+		var n int64
+		if n, err = strconv.ParseInt(num, 0, 64); err != nil {
+			return 0, fmt.Errorf("'%s' is not a number", s)
+		}
+		f = float64(n)
+	}
+	return f * mul, nil
+}
+
+// FormatInt writes an integer in the shortest form with an SI or IEC suffix
+// that parses back to the same value, such as 64M, 4Ki or 1.5G.
+func FormatInt(n int64) string {
+	return format(n, "")
+}
+
+// FormatSize writes a size in bytes in the shortest form with an IEC or SI
+// unit that represents it exactly, such as 512MiB or 1.5GB.
+func FormatSize(n int64) string {
+	return format(n, "B")
+}
+
+func format(n int64, b string) string {
+	if n == 0 {
+		return "0" + b
+	}
+	best := strconv.FormatInt(n, 10)
+	shorter := func(c string) {
+		if len(c) < len(best) {
+			best = c
+		}
+	}
+	for _, u := range iec {
+		if n%int64(u.mul) == 0 {
+			shorter(strconv.FormatInt(n/int64(u.mul), 10) + u.suffix)
+			break
+		}
+	}
+	for _, u := range si {
+		if n%int64(u.mul) == 0 {
+			shorter(strconv.FormatInt(n/int64(u.mul), 10) + u.suffix)
+			break
+		}
+	}
+	// up to three decimal places of the largest SI unit below the value
+	for _, u := range si {
+		v := float64(n) / u.mul
+		if math.Abs(v) < 1 {
+			continue
+		}
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 <= 3 {
+			if back, err := parseInt(s+u.suffix, false); err == nil &&
+				back == n {
+
+				shorter(s + u.suffix)
+			}
+		}
+		break
+	}
+	return best + b
+}
+
+// FormatFloat writes a number with the SI suffix that leaves one to three
+// whole digits, if it parses back to the same value and is not longer than
+// the number without a suffix.
+func FormatFloat(f float64) string {
+	plain := strconv.FormatFloat(f, 'f', -1, 64)
+	if f == 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return plain
+	}
+	for _, u := range append(si[:len(si):len(si)], siSmall[0], siSmall[1],
+		siSmall[3]) {
+
+		v := f / u.mul
+		if math.Abs(v) < 1 || math.Abs(v) >= 1000 {
+			continue
+		}
+		s := strconv.FormatFloat(v, 'f', -1, 64) + u.suffix
+		// the suffix is kept for large values when it is no longer
+		if back, err := ParseFloat(s); err == nil && back == f &&
+			(len(s) < len(plain) ||
+				len(s) == len(plain) && math.Abs(f) >= 1000) {
+
+			return s
+		}
+	}
+	return plain
+}
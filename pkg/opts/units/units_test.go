@@ -0,0 +1,168 @@
+package units
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int64
+		err  string
+		size bool
+	}{
+		{"64k", 64000, "", false},
+		{"4Ki", 4096, "", false},
+		{"1.5Ki", 1536, "", false},
+		{"1.5k", 1500, "", false},
+		{"-2M", -2000000, "", false},
+		{"-1.5Ki", -1536, "", false},
+		{"1_000", 1000, "", false},
+		{" 7Ei ", 7 << 60, "", false},
+		{"-8Ei", math.MinInt64, "", false},
+		{"9223372036854775807", math.MaxInt64, "", false},
+		{"0x10", 16, "", false},
+		{"-0x10", -16, "", false},
+		{"0b101", 5, "", false},
+		{"0o17", 15, "", false},
+		{"0x10k", 0, "is not a number", false},
+		{"0x10Ki", 0, "is not a number", true},
+		{"9223372036854775807k", 0, "is out of range", false},
+		{"8Ei", 0, "is out of range", false},
+		{"9.3E", 0, "is out of range", false},
+		{"1.2345k", 0, "is not a whole number", false},
+		{"0.5", 0, "is not a whole number", false},
+		{"1.5", 0, "is not a whole number", true},
+		{"", 0, "no number", false},
+		{"k", 0, "no number", false},
+		{"1x", 0, "unknown unit 'x'", false},
+		// integers are case sensitive and have no fractional units
+		{"1K", 0, "unknown unit 'K'", false},
+		{"1m", 0, "unknown unit 'm'", false},
+		{"1kB", 0, "unknown unit 'kB'", false},
+		{"1ki", 1024, "", false},
+		// sizes ignore case and allow a trailing B
+		{"1K", 1000, "", true},
+		{"1kib", 1024, "", true},
+		{"1KIB", 1024, "", true},
+		{"512MiB", 512 << 20, "", true},
+		{"1mb", 1000000, "", true},
+		{"1.5GB", 1500000000, "", true},
+		{"1B", 1, "", true},
+		{"0B", 0, "", true},
+		{"0b", 0, "", true},
+		{"0b11B", 0, "is not a number", true},
+		{"1b", 1, "", true},
+		{"1x", 0, "unknown unit 'x'", true},
+	}
+	for _, test := range tests {
+		parse := ParseInt
+		if test.size {
+			parse = ParseSize
+		}
+		n, err := parse(test.s)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parse(%q) = %d, %v, want error %q", test.s, n, err,
+					test.err)
+			}
+		case err != nil:
+			t.Errorf("parse(%q): %v", test.s, err)
+		case n != test.n:
+			t.Errorf("parse(%q) = %d, want %d", test.s, n, test.n)
+		}
+	}
+}
+
+func TestFormatInt(t *testing.T) {
+	tests := []struct {
+		n       int64
+		i, size string
+	}{
+		{0, "0", "0B"},
+		{1, "1", "1B"},
+		{1000, "1k", "1kB"},
+		{1024, "1Ki", "1KiB"},
+		{-4096, "-4Ki", "-4KiB"},
+		{1536, "1536", "1536B"},
+		{64000000, "64M", "64MB"},
+		{1500000000, "1.5G", "1.5GB"},
+		{512 << 20, "512Mi", "512MiB"},
+		{7 << 60, "7Ei", "7EiB"},
+		{math.MaxInt64, "9223372036854775807", "9223372036854775807B"},
+		{math.MinInt64, "-8Ei", "-8EiB"},
+	}
+	for _, test := range tests {
+		if s := FormatInt(test.n); s != test.i {
+			t.Errorf("FormatInt(%d) = %q, want %q", test.n, s, test.i)
+		}
+		if s := FormatSize(test.n); s != test.size {
+			t.Errorf("FormatSize(%d) = %q, want %q", test.n, s, test.size)
+		}
+	}
+}
+
+func TestFormatParseInt(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 999, 1000, 1001, 1023, 1024, 1536,
+		1500, -1500, 1234567, 1 << 40, 5 << 50, 123456789000,
+		math.MaxInt64, math.MinInt64, math.MaxInt64 - 1, math.MinInt64 + 1} {
+
+		if v, err := ParseInt(FormatInt(n)); err != nil || v != n {
+			t.Errorf("ParseInt(FormatInt(%d)) = %d, %v from %q", n, v, err,
+				FormatInt(n))
+		}
+		if v, err := ParseSize(FormatSize(n)); err != nil || v != n {
+			t.Errorf("ParseSize(FormatSize(%d)) = %d, %v from %q", n, v, err,
+				FormatSize(n))
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		s   string
+		f   float64
+		err string
+	}{
+		{"1.5m", 0.0015, ""},
+		{"2u", 2e-6, ""},
+		{"2µ", 2e-6, ""},
+		{"1n", 1e-9, ""},
+		{"2.5k", 2500, ""},
+		{"1Ki", 1024, ""},
+		{"-1.5M", -1.5e6, ""},
+		{"0x10", 16, ""},
+		{"1_000.5", 1000.5, ""},
+		{"1K", 0, "unknown unit 'K'"},
+		{"abc", 0, "unknown unit 'abc'"},
+		{"", 0, "is not a number"},
+	}
+	for _, test := range tests {
+		f, err := ParseFloat(test.s)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ParseFloat(%q) = %v, %v, want error %q", test.s, f,
+					err, test.err)
+			}
+		case err != nil:
+			t.Errorf("ParseFloat(%q): %v", test.s, err)
+		case f != test.f:
+			t.Errorf("ParseFloat(%q) = %v, want %v", test.s, f, test.f)
+		}
+	}
+}
+
+func TestFormatParseFloat(t *testing.T) {
+	for _, f := range []float64{0, 1, -1, 0.5, 0.0015, 2500, -2.5e6,
+		123456.789, 1e-9, 3.14159, 1e18, 12e15} {
+
+		if v, err := ParseFloat(FormatFloat(f)); err != nil || v != f {
+			t.Errorf("ParseFloat(FormatFloat(%v)) = %v, %v from %q", f, v,
+				err, FormatFloat(f))
+		}
+	}
+}
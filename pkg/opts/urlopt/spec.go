@@ -0,0 +1,112 @@
+package urlopt
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cybriq/proc/pkg/opts"
+	"github.com/cybriq/proc/pkg/opts/config"
+	"github.com/cybriq/proc/pkg/opts/meta"
+)
+
+// Opt is an option holding an absolute URL, optionally restricted to some
+// schemes with Schemes. An empty value is no URL.
+type Opt struct {
+	*opts.Typed[*url.URL]
+	s []string
+	h []Hook
+}
+
+var _ config.Option = &Opt{}
+
+type Hook func(*Opt) error
+
+func New(m meta.Data, h ...Hook) (o *Opt) {
+	o = &Opt{h: h}
+	o.Typed = opts.NewTyped(meta.New(m, meta.URL), o.parse, format,
+		func(c *config.Concrete, v func() *url.URL) {
+			c.Text = func() string { return format(v()) }
+		})
+	o.SetHooks(o.RunHooks)
+	_ = o.FromString(m.Default)
+	return
+}
+
+// Schemes restricts the URL to the given schemes, such as http and https.
+func (o *Opt) Schemes(schemes ...string) *Opt {
+	o.s = schemes
+	if v, e := o.Parse(o.Meta().Default()); e == nil {
+		o.Store(v)
+	}
+	return o
+}
+
+func (o *Opt) ToOption() config.Option { return o }
+
+// AllowedSchemes returns the schemes the URL is restricted to, none if any
+// scheme is allowed.
+func (o *Opt) AllowedSchemes() []string { return o.s }
+
+func (o *Opt) RunHooks() (e error) {
+	return opts.RunHooks(o, o.h)
+}
+
+func (o *Opt) FromValue(v *url.URL) *Opt {
+	log.E.Chk(o.Set(v))
+	return o
+}
+
+// parse reads an absolute URL and checks its scheme. An empty string is no
+// URL.
+func (o *Opt) parse(s string) (u *url.URL, e error) {
+	if s == "" {
+		return nil, nil
+	}
+	if u, e = url.Parse(s); e != nil {
+		return nil, fmt.Errorf("invalid URL '%s': %w", s, cause(e))
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("URL '%s' has no scheme, such as https://", s)
+	}
+	if len(o.s) > 0 {
+		var ok bool
+		for _, scheme := range o.s {
+			ok = ok || strings.EqualFold(scheme, u.Scheme)
+		}
+		if !ok {
+			return nil, fmt.Errorf("scheme '%s' of URL '%s' is not allowed, "+
+				"expected one of: %s", u.Scheme, s, strings.Join(o.s, ", "))
+		}
+	}
+	if u.Opaque == "" && u.Host == "" && u.Path == "" {
+		return nil, fmt.Errorf("URL '%s' has no host or path", s)
+	}
+	return
+}
+
+// cause returns the cause of a url.Error, as the URL is already in the
+// message.
+func cause(e error) error {
+	if ue, ok := e.(*url.Error); ok {
+		return ue.Err
+	}
+	return e
+}
+
+func format(v *url.URL) string {
+	if v != nil {
+		return v.String()
+	}
+	return ""
+}
+
+// URL returns a copy of the URL, which is nil if there is none.
+func (o *Opt) URL() *url.URL {
+	v := o.Load()
+	if v == nil {
+		return nil
+	}
+	u := *v
+	return &u
+}
@@ -32,7 +32,8 @@ func (p Path) Parent() (p1 Path) {
 }
 
 func (p Path) Child(child string) (p1 Path) {
-	p1 = append(p, child)
+	// limiting the capacity forces a copy so siblings never share storage
+	p1 = append(p[:len(p):len(p)], child)
 	// log.I.Ln(p, p1)
 	return
 }
@@ -0,0 +1,46 @@
+package util
+
+// Distance returns the Levenshtein edit distance between two strings,
+// compared as normalised by Norm.
+func Distance(a, b string) int {
+	ra, rb := []rune(Norm(a)), []rune(Norm(b))
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Suggest returns the candidate closest to name, or an empty string if none
+// is close enough to be a likely misspelling.
+func Suggest(name string, candidates []string) (s string) {
+	best := len(name)/3 + 1
+	for _, c := range candidates {
+		if d := Distance(name, c); d < best {
+			best, s = d, c
+		}
+	}
+	return
+}